@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketclaim"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "report bucketClaims and buckets left in an inconsistent state, and optionally repair them",
+	Long: `Check reports bucketClaims whose status names a missing bucket or a bucket bound to
+another bucketClaim, buckets bound to a recreated bucketClaim, and bucketClaims whose
+finalizer and status disagree. Repairs should only run while the controller is stopped.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(c *cobra.Command, args []string) error {
+		repair, _ := c.Flags().GetBool("repair")
+		output, _ := c.Flags().GetString("output")
+		return check(c.Context(), os.Stdout, repair, output)
+	},
+}
+
+func init() {
+	checkCmd.Flags().Bool("repair", false, "repair the inconsistencies found")
+	checkCmd.Flags().StringP("output", "o", "json", "output format, one of json or text")
+	cmd.AddCommand(checkCmd)
+}
+
+func check(ctx context.Context, out io.Writer, repair bool, output string) error {
+	if output != "json" && output != "text" {
+		return fmt.Errorf("--output must be one of json or text, got %q", output)
+	}
+	if err := readConfigFile(); err != nil {
+		return err
+	}
+	kubeClient, bucketClient, err := newClients()
+	if err != nil {
+		return err
+	}
+
+	claimOptions, err := bucketClaimListenerOptions()
+	if err != nil {
+		return err
+	}
+	eventRecorder, stopRecording, err := newEventRecorder(kubeClient)
+	if err != nil {
+		return err
+	}
+	defer stopRecording()
+
+	// Repairs provision bucketClaims again, with the settings of the
+	// controller
+	listener := bucketclaim.NewBucketClaimListener(claimOptions...)
+	listener.InitializeKubeClient(kubeClient)
+	listener.InitializeBucketClient(bucketClient)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	findings, err := bucketclaim.NewConsistencyChecker(listener, repair).Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	if output == "text" {
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tBUCKETCLAIM\tBUCKET\tREPAIRED\tMESSAGE")
+		for _, f := range findings {
+			message := f.Message
+			if f.RepairError != "" {
+				message = fmt.Sprintf("%s: repair failed: %s", message, f.RepairError)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", f.Kind, f.BucketClaim, f.Bucket, f.Repaired, message)
+		}
+		return w.Flush()
+	}
+
+	enc := json.NewEncoder(out)
+	for _, f := range findings {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkingBucketListener runs the startup consistency check once the
+// controller leads, before any of its workers start. The controller
+// initializes the bucket listener first, and each listener right before
+// starting its workers, so that repairs do not race with the workers over
+// the same bucketClaims.
+type checkingBucketListener struct {
+	*bucketclaim.BucketListener
+	ctx     context.Context
+	checker *bucketclaim.ConsistencyChecker
+	once    sync.Once
+}
+
+// InitializeEventRecorder is the last initialization of the bucket listener
+func (l *checkingBucketListener) InitializeEventRecorder(er record.EventRecorder) {
+	l.BucketListener.InitializeEventRecorder(er)
+	l.once.Do(func() {
+		runStartupCheck(l.ctx, l.checker)
+	})
+}
+
+// runStartupCheck runs the consistency check and logs each finding
+func runStartupCheck(ctx context.Context, checker *bucketclaim.ConsistencyChecker) {
+	findings, err := checker.Check(ctx)
+	if err != nil {
+		klog.ErrorS(err, "Startup consistency check error")
+		return
+	}
+	for _, f := range findings {
+		klog.InfoS("Inconsistent state found",
+			"kind", f.Kind,
+			"bucketClaim", f.BucketClaim,
+			"bucket", f.Bucket,
+			"message", f.Message,
+			"repaired", f.Repaired,
+			"repairError", f.RepairError)
+	}
+	klog.InfoS("Startup consistency check done", "findings", len(findings))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/viper"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	bucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned"
+)
+
+// newClients builds the kubernetes and bucket clients shared by the
+// controller, its informers and its health checks
+func newClients() (kubeclientset.Interface, bucketclientset.Interface, error) {
+	cfg, err := restConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.QPS = float32(viper.GetFloat64("kube-api-qps"))
+	cfg.Burst = viper.GetInt("kube-api-burst")
+	cfg.UserAgent = rest.DefaultKubernetesUserAgent() + "/" + viper.GetString("identity")
+
+	kubeClient, err := kubeclientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	bucketClient, err := bucketclientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kubeClient, bucketClient, nil
+}
+
+// newEventRecorder returns a recorder of events on COSI objects, for the
+// listeners the controller does not initialize itself. The returned function
+// stops recording.
+func newEventRecorder(kubeClient kubeclientset.Interface) (record.EventRecorder, func(), error) {
+	eventScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(eventScheme); err != nil {
+		return nil, nil, err
+	}
+	if err := v1alpha1.AddToScheme(eventScheme); err != nil {
+		return nil, nil, err
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(eventScheme, v1.EventSource{Component: viper.GetString("identity")})
+	return recorder, broadcaster.Shutdown, nil
+}
+
+// restConfig loads the client configuration from, in order
+//   - the --kubeconfig flag
+//   - the KUBECONFIG environment variable
+//   - the service account of the pod, when running in a cluster
+//   - $HOME/.kube/config
+//
+// --context selects a context other than the current one of the kubeconfig
+func restConfig() (*rest.Config, error) {
+	kubeConfigPath := viper.GetString("kubeconfig")
+	kubeContext := viper.GetString("context")
+
+	if kubeConfigPath == "" && os.Getenv(clientcmd.RecommendedConfigPathEnvVar) == "" && kubeContext == "" {
+		cfg, err := rest.InClusterConfig()
+		if err == nil {
+			klog.V(3).InfoS("Using in-cluster client configuration")
+			return cfg, nil
+		} else if err != rest.ErrNotInCluster {
+			return nil, err
+		}
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeConfigPath
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	klog.V(3).InfoS("Using kubeconfig client configuration", "kubeconfig", kubeConfigPath, "context", kubeContext)
+	return cfg, nil
+}
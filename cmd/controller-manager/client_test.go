@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// writeKubeConfig writes a kubeconfig with a context per server, the first
+// one being the current context
+func writeKubeConfig(t *testing.T, name string, servers ...string) string {
+	t.Helper()
+
+	config := "apiVersion: v1\nkind: Config\ncurrent-context: " + servers[0] + "\nclusters:\n"
+	for _, server := range servers {
+		config += fmt.Sprintf("- name: %s\n  cluster:\n    server: https://%s\n", server, server)
+	}
+	config += "contexts:\n"
+	for _, server := range servers {
+		config += fmt.Sprintf("- name: %s\n  context:\n    cluster: %s\n    user: user\n", server, server)
+	}
+	config += "users:\n- name: user\n  user:\n    token: token\n"
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("Error occurred when writing kubeconfig: %v", err)
+	}
+	return path
+}
+
+// Test the order in which the client configuration is loaded
+func TestRestConfig(t *testing.T) {
+	flagConfig := writeKubeConfig(t, "flag", "flag-cluster", "flag-other")
+	envConfig := writeKubeConfig(t, "env", "env-cluster", "env-other")
+
+	for _, tc := range []struct {
+		name         string
+		kubeConfig   string
+		context      string
+		envConfig    string
+		inCluster    bool
+		expectedHost string
+	}{
+		{
+			name:         "Flag",
+			kubeConfig:   flagConfig,
+			expectedHost: "https://flag-cluster",
+		},
+		{
+			name:         "FlagOverEnv",
+			kubeConfig:   flagConfig,
+			envConfig:    envConfig,
+			expectedHost: "https://flag-cluster",
+		},
+		{
+			name:         "FlagOverInCluster",
+			kubeConfig:   flagConfig,
+			inCluster:    true,
+			expectedHost: "https://flag-cluster",
+		},
+		{
+			name:         "Env",
+			envConfig:    envConfig,
+			expectedHost: "https://env-cluster",
+		},
+		{
+			name:         "EnvOverInCluster",
+			envConfig:    envConfig,
+			inCluster:    true,
+			expectedHost: "https://env-cluster",
+		},
+		{
+			name:         "Context",
+			kubeConfig:   flagConfig,
+			context:      "flag-other",
+			expectedHost: "https://flag-other",
+		},
+		{
+			name:         "ContextOverInCluster",
+			envConfig:    envConfig,
+			context:      "env-other",
+			inCluster:    true,
+			expectedHost: "https://env-other",
+		},
+		{
+			// The service account token is not mounted in tests, the
+			// in-cluster configuration is tried and fails
+			name:      "InCluster",
+			inCluster: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("kubeconfig", tc.kubeConfig)
+			viper.Set("context", tc.context)
+			t.Cleanup(func() {
+				viper.Set("kubeconfig", "")
+				viper.Set("context", "")
+			})
+			t.Setenv(clientcmd.RecommendedConfigPathEnvVar, tc.envConfig)
+			host, port := "", ""
+			if tc.inCluster {
+				host, port = "10.0.0.1", "443"
+			}
+			t.Setenv("KUBERNETES_SERVICE_HOST", host)
+			t.Setenv("KUBERNETES_SERVICE_PORT", port)
+
+			cfg, err := restConfig()
+			if tc.expectedHost == "" {
+				if err == nil {
+					t.Errorf("expected in-cluster configuration to be used got host %s", cfg.Host)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error occurred when loading client configuration: %v", err)
+			}
+			if cfg.Host != tc.expectedHost {
+				t.Errorf("expected host %s got %s", tc.expectedHost, cfg.Host)
+			}
+		})
+	}
+}
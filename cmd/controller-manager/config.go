@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const configMapPollPeriod = 30 * time.Second
+
+// reloadableSettings are applied again whenever the configuration changes.
+// Every other setting takes effect on the next restart.
+var reloadableSettings = []string{"v", "bucket-naming-strategy", "bucket-name-template", "default-bucket-class"}
+
+// readConfigFile loads the --config file, if any. Its keys are the names of
+// the command line flags, which take precedence over it along with their
+// environment variables.
+func readConfigFile() error {
+	configFile := viper.GetString("config")
+	if configFile == "" {
+		return nil
+	}
+	if viper.GetString("config-map") != "" {
+		return fmt.Errorf("--config and --config-map are mutually exclusive")
+	}
+
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("reading config file %s: %w", configFile, err)
+	}
+	klog.V(3).InfoS("Loaded config file", "path", configFile)
+	return nil
+}
+
+// configMapSource reads the configuration from a key of a ConfigMap
+type configMapSource struct {
+	kubeClient kubeclientset.Interface
+	namespace  string
+	name       string
+	key        string
+
+	resourceVersion string
+}
+
+// newConfigMapSource returns the configMapSource selected by --config-map,
+// or nil if it is not set
+func newConfigMapSource(kubeClient kubeclientset.Interface) (*configMapSource, error) {
+	ref := viper.GetString("config-map")
+	if ref == "" {
+		return nil, nil
+	}
+
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("--config-map must be of the form namespace/name, got %q", ref)
+	}
+	return &configMapSource{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		name:       name,
+		key:        viper.GetString("config-map-key"),
+	}, nil
+}
+
+// read loads the ConfigMap into viper. It reports whether the ConfigMap
+// changed since the last read.
+func (c *configMapSource) read(ctx context.Context) (bool, error) {
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("reading config map %s/%s: %w", c.namespace, c.name, err)
+	}
+	if configMap.ObjectMeta.ResourceVersion == c.resourceVersion {
+		return false, nil
+	}
+
+	data, ok := configMap.Data[c.key]
+	if !ok {
+		return false, fmt.Errorf("config map %s/%s has no key %q", c.namespace, c.name, c.key)
+	}
+
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(bytes.NewBufferString(data)); err != nil {
+		return false, fmt.Errorf("reading config map %s/%s: %w", c.namespace, c.name, err)
+	}
+	c.resourceVersion = configMap.ObjectMeta.ResourceVersion
+	klog.V(3).InfoS("Loaded config map", "name", c.name, "ns", c.namespace, "resourceVersion", c.resourceVersion)
+	return true, nil
+}
+
+// watch polls the ConfigMap until ctx is cancelled and calls apply after
+// every change
+func (c *configMapSource) watch(ctx context.Context, apply func()) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		changed, err := c.read(ctx)
+		if err != nil {
+			klog.ErrorS(err, "Reload configuration error")
+			return
+		}
+		if changed {
+			reloaded(apply)
+		}
+	}, configMapPollPeriod)
+}
+
+// watchConfigFile calls apply whenever the --config file changes. Updates of
+// a mounted ConfigMap are picked up as well.
+func watchConfigFile(apply func()) {
+	if viper.GetString("config") == "" {
+		return
+	}
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reloaded(apply)
+	})
+	viper.WatchConfig()
+}
+
+func reloaded(apply func()) {
+	klog.InfoS("Configuration changed, reloading", "settings", reloadableSettings)
+	apply()
+}
+
+// setVerbosity applies the "v" setting to klog
+func setVerbosity() {
+	if err := klogFlags.Set("v", viper.GetString("v")); err != nil {
+		klog.ErrorS(err, "Invalid verbosity", "v", viper.GetString("v"))
+	}
+}
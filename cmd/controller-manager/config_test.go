@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/spf13/viper"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketclaim"
+)
+
+// Test that every reloadable setting is a flag
+func TestReloadableSettings(t *testing.T) {
+	for _, name := range reloadableSettings {
+		if cmd.PersistentFlags().Lookup(name) == nil {
+			t.Errorf("reloadable setting %q is not a flag", name)
+		}
+	}
+}
+
+// Test that changes of the ConfigMap are reloaded into the reloadable settings
+func TestConfigMapSource(t *testing.T) {
+	ctx := context.TODO()
+	t.Cleanup(func() {
+		viper.SetConfigType("yaml")
+		viper.ReadConfig(bytes.NewBufferString("{}"))
+	})
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "cosi-config",
+			Namespace:       "cosi-system",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			"config.yaml": "bucket-naming-strategy: hashed\ndefault-bucket-class: standard\n",
+		},
+	}
+	kubeClient := fakekubeclientset.NewSimpleClientset(configMap)
+	source := &configMapSource{
+		kubeClient: kubeClient,
+		namespace:  configMap.Namespace,
+		name:       configMap.Name,
+		key:        "config.yaml",
+	}
+
+	read := func(expectedChanged bool) {
+		t.Helper()
+		changed, err := source.read(ctx)
+		if err != nil {
+			t.Fatalf("Error occurred when reading ConfigMap: %v", err)
+		}
+		if changed != expectedChanged {
+			t.Errorf("expected changed %t got %t", expectedChanged, changed)
+		}
+	}
+	expectSettings := func(strategy, template, bucketClass string) {
+		t.Helper()
+		for name, expected := range map[string]string{
+			"bucket-naming-strategy": strategy,
+			"bucket-name-template":   template,
+			"default-bucket-class":   bucketClass,
+		} {
+			if value := viper.GetString(name); value != expected {
+				t.Errorf("expected %s %q got %q", name, expected, value)
+			}
+		}
+		if _, err := bucketClaimListenerOptions(); err != nil {
+			t.Errorf("Error occurred when building listener options: %v", err)
+		}
+	}
+
+	read(true)
+	expectSettings(bucketclaim.NamingStrategyHashed, "", "standard")
+
+	// An unchanged ConfigMap is not applied again
+	read(false)
+
+	configMap.ResourceVersion = "2"
+	configMap.Data["config.yaml"] = "bucket-naming-strategy: template\nbucket-name-template: \"{{.Namespace}}-{{.Name}}\"\n"
+	if _, err := kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Error occurred when updating ConfigMap: %v", err)
+	}
+	read(true)
+	// Settings removed from the ConfigMap fall back to their flag default
+	expectSettings(bucketclaim.NamingStrategyTemplate, "{{.Namespace}}-{{.Name}}", "")
+
+	source.key = "missing.yaml"
+	source.resourceVersion = ""
+	if _, err := source.read(ctx); err == nil {
+		t.Errorf("expected an error for a missing key")
+	}
+}
@@ -3,15 +3,25 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/client-go/util/workqueue"
 
+	bucketinformers "sigs.k8s.io/container-object-storage-interface-api/client/informers/externalversions"
 	bucketcontroller "sigs.k8s.io/container-object-storage-interface-api/controller"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketaccess"
 	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketclaim"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/health"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/webhook"
 
 	"k8s.io/klog/v2"
 )
@@ -27,18 +37,73 @@ var cmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 }
 
+const (
+	informerResyncPeriod  = 30 * time.Second
+	leaseObservePeriod    = 10 * time.Second
+	reconcileStallTimeout = 5 * time.Minute
+)
+
 var kubeConfig string
 var verbosity int
+var klogFlags = flag.NewFlagSet("klog", flag.ExitOnError)
 
 func init() {
+	// Every flag can also be set from the environment, e.g. --leader-election-namespace
+	// from LEADER_ELECTION_NAMESPACE. There is no prefix, --kubeconfig keeps
+	// being read from KUBECONFIG.
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
 
 	flag.Set("alsologtostderr", "true")
-	kflags := flag.NewFlagSet("klog", flag.ExitOnError)
-	klog.InitFlags(kflags)
+	klog.InitFlags(klogFlags)
 
-	cmd.PersistentFlags().AddGoFlagSet(kflags)
-	cmd.PersistentFlags().StringVarP(&kubeConfig, "kubeconfig", "", kubeConfig, "path to kubeconfig file")
+	cmd.PersistentFlags().AddGoFlagSet(klogFlags)
+	cmd.PersistentFlags().String("config", "",
+		"path to a YAML config file whose keys are the names of these flags, e.g. a mounted ConfigMap")
+	cmd.PersistentFlags().String("config-map", "",
+		"namespace/name of a ConfigMap to read the config file from instead of --config")
+	cmd.PersistentFlags().String("config-map-key", "config.yaml", "key of the config file in --config-map")
+	cmd.PersistentFlags().StringVarP(&kubeConfig, "kubeconfig", "", kubeConfig, "path to kubeconfig file. Defaults to $KUBECONFIG, the in-cluster configuration, then $HOME/.kube/config")
+	cmd.PersistentFlags().String("context", "", "kubeconfig context to use instead of the current context")
+	cmd.PersistentFlags().Float32("kube-api-qps", 20, "maximum sustained queries per second to the Kubernetes API server")
+	cmd.PersistentFlags().Int("kube-api-burst", 30, "maximum burst of queries to the Kubernetes API server")
+	cmd.PersistentFlags().String("bucket-naming-strategy", bucketclaim.NamingStrategyConcat,
+		"strategy used to name generated buckets, one of concat, class-uid, hashed or template")
+	cmd.PersistentFlags().String("bucket-name-template", "",
+		"Go template used by the template naming strategy, e.g. {{.Namespace}}-{{.Name}}")
+	cmd.PersistentFlags().String("default-bucket-class", "",
+		"bucketClass used for bucketClaims without one when no bucketClass is annotated as the default")
+	cmd.PersistentFlags().Duration("gc-period", 10*time.Minute,
+		"period of the collection of buckets whose bucketClaim was deleted while the controller was down. Disabled when 0")
+	cmd.PersistentFlags().Bool("gc-dry-run", false,
+		"only log the buckets the garbage collector would release or delete")
+	cmd.PersistentFlags().Bool("startup-check", true,
+		"log bucketClaims and buckets left in an inconsistent state once this replica leads, before it processes any object")
+	cmd.PersistentFlags().Bool("startup-repair", false,
+		"repair the inconsistencies found by the startup check")
+	cmd.PersistentFlags().String("metrics-address", "",
+		"address to serve Prometheus metrics on at /metrics, e.g. :8080. Metrics are disabled when empty")
+	cmd.PersistentFlags().String("health-probe-address", "",
+		"address to serve the /healthz and /readyz probes on, e.g. :8081. Probes are disabled when empty")
+	cmd.PersistentFlags().String("webhook-address", "",
+		"address to serve the validating admission webhooks on over TLS, e.g. :9443. Webhooks are disabled when empty")
+	cmd.PersistentFlags().String("webhook-cert-file", "", "path to the TLS certificate of the webhook server")
+	cmd.PersistentFlags().String("webhook-key-file", "", "path to the TLS private key of the webhook server")
+	cmd.PersistentFlags().String("identity", "cosi-controller-manager",
+		"identity of this controller, distinguishes the leader election lease of separate COSI installations")
+	cmd.PersistentFlags().Int("workers", 40, "number of objects processed concurrently")
+	cmd.PersistentFlags().Bool("leader-elect", true,
+		"elect a leader among the replicas before processing objects. Only disable it for local runs of a single replica per host")
+	cmd.PersistentFlags().String("leader-election-lease-name", "leader-lock",
+		"name of the leader election lease, combined with --identity")
+	cmd.PersistentFlags().String("leader-election-namespace", "",
+		"namespace of the leader election lease and of recorded events. Defaults to the namespace of the pod")
+	cmd.PersistentFlags().Duration("leader-election-lease-duration", 150*time.Second,
+		"duration non-leader replicas wait before taking over an unrenewed lease")
+	cmd.PersistentFlags().Duration("leader-election-renew-deadline", 120*time.Second,
+		"duration the leader retries renewing its lease before giving up leadership")
+	cmd.PersistentFlags().Duration("leader-election-retry-period", 60*time.Second,
+		"duration replicas wait between attempts to acquire or renew the lease")
 
 	//flag.CommandLine.Parse([]string{})
 	viper.BindPFlags(cmd.PersistentFlags())
@@ -62,10 +127,215 @@ func main() {
 }
 
 func run(ctx context.Context, args []string) error {
-	ctrl, err := bucketcontroller.NewDefaultObjectStorageController("cosi-controller-manager", "leader-lock", 40)
+	// Settings of the clients can only come from the flags, the environment
+	// and the config file, as the ConfigMap is read with these clients
+	if err := readConfigFile(); err != nil {
+		return err
+	}
+	if qps, burst := viper.GetFloat64("kube-api-qps"), viper.GetInt("kube-api-burst"); qps <= 0 || burst < 1 {
+		return fmt.Errorf("--kube-api-qps and --kube-api-burst must be positive, got %v and %d", qps, burst)
+	}
+	kubeClient, bucketClient, err := newClients()
+	if err != nil {
+		return err
+	}
+
+	configMap, err := newConfigMapSource(kubeClient)
+	if err != nil {
+		return err
+	}
+	if configMap != nil {
+		if _, err := configMap.read(ctx); err != nil {
+			return err
+		}
+	}
+	setVerbosity()
+
+	identity := viper.GetString("identity")
+	workers := viper.GetInt("workers")
+	if workers < 1 {
+		return fmt.Errorf("--workers must be at least 1, got %d", workers)
+	}
+
+	leaseDuration := viper.GetDuration("leader-election-lease-duration")
+	renewDeadline := viper.GetDuration("leader-election-renew-deadline")
+	retryPeriod := viper.GetDuration("leader-election-retry-period")
+	if retryPeriod <= 0 || renewDeadline <= retryPeriod || leaseDuration <= renewDeadline {
+		return fmt.Errorf("leader election durations must satisfy lease duration (%s) > renew deadline (%s) > retry period (%s) > 0",
+			leaseDuration, renewDeadline, retryPeriod)
+	}
+
+	if viper.GetString("webhook-address") != "" && (viper.GetString("webhook-cert-file") == "" || viper.GetString("webhook-key-file") == "") {
+		return fmt.Errorf("--webhook-cert-file and --webhook-key-file are required with --webhook-address")
+	}
+
+	// The ObjectStorageController reads the namespace of its lease and
+	// events from POD_NAMESPACE
+	if ns := viper.GetString("leader-election-namespace"); ns != "" {
+		if err := os.Setenv("POD_NAMESPACE", ns); err != nil {
+			return err
+		}
+	}
+
+	leaseHolder, err := health.LeaseHolderIdentity()
+	if err != nil {
+		return err
+	}
+
+	// The ObjectStorageController always runs leader election. Without it,
+	// this host gets a lease of its own that no other replica competes for.
+	// The name is stable, so restarts take the lease over again, since they
+	// hold it with the same identity, instead of leaving one lease per process
+	// behind.
+	leaderLockName := viper.GetString("leader-election-lease-name")
+	if !viper.GetBool("leader-elect") {
+		leaderLockName = fmt.Sprintf("%s-%s", leaderLockName, leaseHolder)
+		klog.InfoS("Leader election disabled, using a lease private to this host",
+			"name", health.LeaseName(leaderLockName, identity))
+	}
+
+	claimOptions, err := bucketClaimListenerOptions()
+	if err != nil {
+		return err
+	}
+
+	if addr := viper.GetString("metrics-address"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		serveHTTP(ctx, "metrics", addr, mux)
+	}
+
+	informerFactory := bucketinformers.NewSharedInformerFactory(bucketClient, informerResyncPeriod)
+	informers := informerFactory.Objectstorage().V1alpha1()
+	bucketClaimInformer := informers.BucketClaims().Informer()
+	bucketInformer := informers.Buckets().Informer()
+	bucketClassInformer := informers.BucketClasses().Informer()
+	bucketAccessInformer := informers.BucketAccesses().Informer()
+	if err := bucketclaim.AddIndexers(informers); err != nil {
+		return err
+	}
+	if err := bucketaccess.AddIndexers(informers); err != nil {
+		return err
+	}
+	informerFactory.Start(ctx.Done())
+
+	leaderObserver := health.NewLeaderObserver(kubeClient, health.LeaderElectionNamespace(),
+		health.LeaseName(leaderLockName, identity), leaseHolder)
+	go leaderObserver.Run(ctx, leaseObservePeriod)
+
+	if addr := viper.GetString("health-probe-address"); addr != "" {
+		checker := health.NewChecker()
+		checker.AddHealthCheck("reconcile", health.ProgressStalled(metrics.ReconcileProgress, reconcileStallTimeout))
+		checker.AddReadyCheck("informers", health.InformersSynced(
+			bucketClaimInformer.HasSynced, bucketInformer.HasSynced, bucketClassInformer.HasSynced, bucketAccessInformer.HasSynced))
+		checker.AddReadyCheck("leader-election", leaderObserver.Decided)
+		serveHTTP(ctx, "health", addr, checker.Handler())
+	}
+
+	rateLimit := workqueue.NewItemExponentialFailureRateLimiter(100*time.Millisecond, 30*time.Second)
+	ctrl, err := bucketcontroller.NewObjectStorageControllerWithClientset(identity, leaderLockName, workers, rateLimit, kubeClient, bucketClient)
 	if err != nil {
 		return err
 	}
-	ctrl.AddBucketClaimListener(bucketclaim.NewBucketClaimListener())
+	ctrl.LeaseDuration = leaseDuration
+	ctrl.RenewDeadline = renewDeadline
+	ctrl.RetryPeriod = retryPeriod
+
+	bucketClaimListener := bucketclaim.NewBucketClaimListener(
+		append([]bucketclaim.Option{bucketclaim.WithInformers(informers)}, claimOptions...)...)
+	ctrl.AddBucketClaimListener(bucketClaimListener)
+	reloadableClaimListeners := []*bucketclaim.BucketClaimListener{bucketClaimListener}
+
+	// The startup check runs from the bucket listener, before the controller
+	// starts processing objects
+	var bucketListener bucketcontroller.BucketListener = bucketclaim.NewBucketListener(bucketClaimListener)
+	if viper.GetBool("startup-check") {
+		eventRecorder, stopRecording, err := newEventRecorder(kubeClient)
+		if err != nil {
+			return err
+		}
+		defer stopRecording()
+
+		// Repairs provision bucketClaims again, with the settings of the
+		// controller
+		checkListener := bucketclaim.NewBucketClaimListener(claimOptions...)
+		checkListener.InitializeKubeClient(kubeClient)
+		checkListener.InitializeBucketClient(bucketClient)
+		checkListener.InitializeEventRecorder(eventRecorder)
+		reloadableClaimListeners = append(reloadableClaimListeners, checkListener)
+		bucketListener = &checkingBucketListener{
+			BucketListener: bucketclaim.NewBucketListener(bucketClaimListener),
+			ctx:            ctx,
+			checker:        bucketclaim.NewConsistencyChecker(checkListener, viper.GetBool("startup-repair")),
+		}
+	}
+	ctrl.AddBucketListener(bucketListener)
+	ctrl.AddBucketClassListener(bucketclaim.NewBucketClassListener(bucketClaimListener))
+	bucketAccessListener := bucketaccess.NewBucketAccessListener(bucketaccess.WithInformers(informers))
+	ctrl.AddBucketAccessListener(bucketAccessListener)
+	ctrl.AddBucketAccessClassListener(bucketaccess.NewBucketAccessClassListener(bucketAccessListener))
+
+	// Every replica validates admissions, the webhook server gets listeners
+	// of its own as the ones of the controller only get clients once it leads.
+	if addr := viper.GetString("webhook-address"); addr != "" {
+		webhookClaimListener := bucketclaim.NewBucketClaimListener(
+			append([]bucketclaim.Option{bucketclaim.WithInformers(informers)}, claimOptions...)...)
+		webhookClaimListener.InitializeKubeClient(kubeClient)
+		webhookClaimListener.InitializeBucketClient(bucketClient)
+		webhookAccessListener := bucketaccess.NewBucketAccessListener(bucketaccess.WithInformers(informers))
+		webhookAccessListener.InitializeKubeClient(kubeClient)
+		webhookAccessListener.InitializeBucketClient(bucketClient)
+		reloadableClaimListeners = append(reloadableClaimListeners, webhookClaimListener)
+
+		server := webhook.NewServer(webhookClaimListener, webhookAccessListener)
+		serveHTTPS(ctx, "webhook", addr, viper.GetString("webhook-cert-file"), viper.GetString("webhook-key-file"), server.Handler())
+	}
+
+	// The controller only initializes the clients of its listeners once it
+	// leads, so the garbage collector gets a listener of its own.
+	if period := viper.GetDuration("gc-period"); period > 0 {
+		gcListener := bucketclaim.NewBucketClaimListener()
+		gcListener.InitializeKubeClient(kubeClient)
+		gcListener.InitializeBucketClient(bucketClient)
+		gc := bucketclaim.NewGarbageCollector(gcListener, viper.GetBool("gc-dry-run"))
+		go gc.Run(ctx, period, leaderObserver.IsLeader)
+	}
+
+	reload := func() {
+		setVerbosity()
+
+		bucketNamer, err := bucketclaim.NewBucketNamer(viper.GetString("bucket-naming-strategy"), viper.GetString("bucket-name-template"))
+		if err != nil {
+			klog.ErrorS(err, "Invalid bucket naming settings, keeping the previous ones")
+		}
+		for _, listener := range reloadableClaimListeners {
+			if err == nil {
+				listener.SetBucketNamer(bucketNamer)
+			}
+			listener.SetDefaultBucketClassName(viper.GetString("default-bucket-class"))
+		}
+	}
+	if configMap != nil {
+		go configMap.watch(ctx, reload)
+	} else {
+		watchConfigFile(reload)
+	}
+
+	// Reads missing from the caches fall back to the API server, wait for
+	// the caches to avoid that for every object on startup.
+	informerFactory.WaitForCacheSync(ctx.Done())
 	return ctrl.Run(ctx)
 }
+
+// bucketClaimListenerOptions returns the options of the bucketClaim listeners
+// that provision, from the bucket naming and default bucketClass settings
+func bucketClaimListenerOptions() ([]bucketclaim.Option, error) {
+	bucketNamer, err := bucketclaim.NewBucketNamer(viper.GetString("bucket-naming-strategy"), viper.GetString("bucket-name-template"))
+	if err != nil {
+		return nil, err
+	}
+	return []bucketclaim.Option{
+		bucketclaim.WithBucketNamer(bucketNamer),
+		bucketclaim.WithDefaultBucketClassName(viper.GetString("default-bucket-class")),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// serveHTTP serves handler on addr until ctx is cancelled
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler) {
+	serve(ctx, name, addr, handler, func(server *http.Server) error {
+		return server.ListenAndServe()
+	})
+}
+
+// serveHTTPS serves handler on addr over TLS, with the certificate and key
+// read from certFile and keyFile, until ctx is cancelled
+func serveHTTPS(ctx context.Context, name, addr, certFile, keyFile string, handler http.Handler) {
+	serve(ctx, name, addr, handler, func(server *http.Server) error {
+		return server.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+func serve(ctx context.Context, name, addr string, handler http.Handler, listen func(*http.Server) error) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		klog.V(2).InfoS("Starting server", "name", name, "address", addr)
+		if err := listen(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.ErrorS(err, "Server failed", "name", name, "address", addr)
+		}
+	}()
+}
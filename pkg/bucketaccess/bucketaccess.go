@@ -0,0 +1,237 @@
+package bucketaccess
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	bucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned"
+	objectstoragev1alpha1 "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/typed/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-api/controller/events"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// BucketAccessListener is a resource handler for bucket access objects
+type BucketAccessListener struct {
+	eventRecorder record.EventRecorder
+
+	kubeClient   kubeclientset.Interface
+	bucketClient bucketclientset.Interface
+
+	listers *listers
+}
+
+// Option configures a BucketAccessListener
+type Option func(*BucketAccessListener)
+
+func NewBucketAccessListener(opts ...Option) *BucketAccessListener {
+	b := &BucketAccessListener{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Add validates a newly created bucketAccess
+func (b *BucketAccessListener) Add(ctx context.Context, bucketAccess *v1alpha1.BucketAccess) (err error) {
+	klog.V(3).InfoS("Add BucketAccess",
+		"name", bucketAccess.ObjectMeta.Name,
+		"ns", bucketAccess.ObjectMeta.Namespace,
+		"bucketAccessClass", bucketAccess.Spec.BucketAccessClassName,
+		"bucketClaim", bucketAccess.Spec.BucketClaimName,
+	)
+	done := metrics.StartReconcile("BucketAccess", "Add")
+	defer func() { done(err) }()
+
+	if bucketAccess.Status.AccessGranted {
+		return nil
+	}
+
+	err = b.validateBucketAccessOperation(ctx, bucketAccess)
+	if err != nil {
+		klog.V(3).ErrorS(err, "BucketAccess validation failed",
+			"name", bucketAccess.ObjectMeta.Name,
+			"ns", bucketAccess.ObjectMeta.Namespace)
+		return err
+	}
+
+	klog.V(3).InfoS("Add BucketAccess success",
+		"name", bucketAccess.ObjectMeta.Name,
+		"ns", bucketAccess.ObjectMeta.Namespace)
+	return nil
+}
+
+// Update re-validates a bucketAccess that has not been granted yet
+func (b *BucketAccessListener) Update(ctx context.Context, old, new *v1alpha1.BucketAccess) (err error) {
+	klog.V(3).InfoS("Update BucketAccess",
+		"name", old.Name,
+		"ns", old.Namespace)
+	done := metrics.StartReconcile("BucketAccess", "Update")
+	defer func() { done(err) }()
+
+	if !new.GetDeletionTimestamp().IsZero() || new.Status.AccessGranted {
+		return nil
+	}
+
+	err = b.validateBucketAccessOperation(ctx, new)
+	if err != nil {
+		klog.V(3).ErrorS(err, "BucketAccess validation failed",
+			"name", new.ObjectMeta.Name,
+			"ns", new.ObjectMeta.Namespace)
+		return err
+	}
+
+	klog.V(3).InfoS("Update BucketAccess success",
+		"name", new.ObjectMeta.Name,
+		"ns", new.ObjectMeta.Namespace)
+	return nil
+}
+
+// Delete releases the bucketAccessClass of a deleted bucketAccess if it was
+// the last reference to it. Deletes are not retried, a failure is left to the
+// next event of the bucketAccessClass.
+func (b *BucketAccessListener) Delete(ctx context.Context, bucketAccess *v1alpha1.BucketAccess) error {
+	klog.V(3).InfoS("Delete BucketAccess",
+		"name", bucketAccess.ObjectMeta.Name,
+		"ns", bucketAccess.ObjectMeta.Namespace)
+	done := metrics.StartReconcile("BucketAccess", "Delete")
+	defer done(nil)
+
+	err := b.syncBucketAccessClassProtection(ctx, bucketAccess.Spec.BucketAccessClassName)
+	if err != nil {
+		klog.V(3).ErrorS(err, "Failed to sync BucketAccessClass protection",
+			"bucketAccessClass", bucketAccess.Spec.BucketAccessClassName,
+			"bucketAccess", bucketAccess.ObjectMeta.Name)
+	}
+	return nil
+}
+
+// validateBucketAccessOperation checks that a bucketAccess refers to objects
+// the sidecar will be able to act on, and protects its bucketAccessClass.
+//
+// Return values
+//   - nil - BucketAccess is valid
+//   - ErrInvalidBucketAccessClass - BucketAccessClassName is empty
+//   - ErrInvalidBucketClaim - BucketClaimName is empty
+//   - ErrUnsupportedProtocol - BucketClaim does not offer the protocol
+//   - ErrBucketClaimNotBound - BucketClaim has no bucket yet           [requeue'd with exponential backoff]
+//   - non-nil err - Internal error                                    [requeue'd with exponential backoff]
+func (b *BucketAccessListener) validateBucketAccessOperation(ctx context.Context, bucketAccess *v1alpha1.BucketAccess) error {
+	bucketAccessClass, bucketClaim, err := b.validateBucketAccess(ctx, bucketAccess)
+	if err != nil {
+		return b.recordError(bucketAccess, v1.EventTypeWarning, events.FailedGrantAccess, err)
+	}
+
+	err = b.protectBucketAccessClass(ctx, bucketAccessClass)
+	if err != nil {
+		klog.V(3).ErrorS(err, "Failed to protect BucketAccessClass", "name", bucketAccessClass.ObjectMeta.Name)
+		return b.recordError(bucketAccess, v1.EventTypeWarning, events.FailedGrantAccess, err)
+	}
+
+	if bucketClaim.Status.BucketName == "" {
+		return b.recordError(bucketAccess, v1.EventTypeNormal, events.WaitingForBucket, util.ErrBucketClaimNotBound)
+	}
+	return nil
+}
+
+// ValidateBucketAccess checks that bucketAccess refers to a bucketAccessClass
+// and a bucketClaim that exist, and requests a protocol of the bucketClaim.
+// It does not require the bucketClaim to be bound. An admission webhook can
+// use it to reject invalid bucketAccesses.
+func (b *BucketAccessListener) ValidateBucketAccess(ctx context.Context, bucketAccess *v1alpha1.BucketAccess) error {
+	_, _, err := b.validateBucketAccess(ctx, bucketAccess)
+	return err
+}
+
+// validateBucketAccess returns the bucketAccessClass and bucketClaim
+// bucketAccess refers to, once they passed the checks of ValidateBucketAccess
+func (b *BucketAccessListener) validateBucketAccess(ctx context.Context, bucketAccess *v1alpha1.BucketAccess) (*v1alpha1.BucketAccessClass, *v1alpha1.BucketClaim, error) {
+	bucketAccessClassName := bucketAccess.Spec.BucketAccessClassName
+	if bucketAccessClassName == "" {
+		return nil, nil, util.ErrInvalidBucketAccessClass
+	}
+
+	bucketAccessClass, err := b.bucketAccessClasses().Get(ctx, bucketAccessClassName, metav1.GetOptions{})
+	if err != nil {
+		if !kubeerrors.IsNotFound(err) {
+			klog.V(3).ErrorS(err, "Get BucketAccessClass error", "name", bucketAccessClassName)
+		}
+		return nil, nil, err
+	}
+
+	bucketClaimName := bucketAccess.Spec.BucketClaimName
+	if bucketClaimName == "" {
+		return nil, nil, util.ErrInvalidBucketClaim
+	}
+
+	// BucketClaims are only ever looked up in the namespace of the
+	// bucketAccess, so a claim from another namespace is reported as missing.
+	bucketClaim, err := b.bucketClaims(bucketAccess.ObjectMeta.Namespace).Get(ctx, bucketClaimName, metav1.GetOptions{})
+	if err != nil {
+		if !kubeerrors.IsNotFound(err) {
+			klog.V(3).ErrorS(err, "Get BucketClaim error", "name", bucketClaimName)
+		}
+		return nil, nil, err
+	}
+
+	for _, protocol := range bucketClaim.Spec.Protocols {
+		if protocol == bucketAccess.Spec.Protocol {
+			return bucketAccessClass, bucketClaim, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %q not in %v", util.ErrUnsupportedProtocol, bucketAccess.Spec.Protocol, bucketClaim.Spec.Protocols)
+}
+
+// InitializeKubeClient initializes the kubernetes client
+func (b *BucketAccessListener) InitializeKubeClient(k kubeclientset.Interface) {
+	b.kubeClient = k
+}
+
+// InitializeBucketClient initializes the object storage bucket client
+func (b *BucketAccessListener) InitializeBucketClient(bc bucketclientset.Interface) {
+	b.bucketClient = bc
+}
+
+// InitializeEventRecorder initializes the event recorder
+func (b *BucketAccessListener) InitializeEventRecorder(er record.EventRecorder) {
+	b.eventRecorder = er
+}
+
+func (b *BucketAccessListener) bucketAccessClasses() objectstoragev1alpha1.BucketAccessClassInterface {
+	if b.bucketClient != nil {
+		return b.bucketClient.ObjectstorageV1alpha1().BucketAccessClasses()
+	}
+	panic("uninitialized listener")
+}
+
+func (b *BucketAccessListener) bucketAccesses(namespace string) objectstoragev1alpha1.BucketAccessInterface {
+	if b.bucketClient != nil {
+		return b.bucketClient.ObjectstorageV1alpha1().BucketAccesses(namespace)
+	}
+	panic("uninitialized listener")
+}
+
+func (b *BucketAccessListener) bucketClaims(namespace string) objectstoragev1alpha1.BucketClaimInterface {
+	if b.bucketClient != nil {
+		return b.bucketClient.ObjectstorageV1alpha1().BucketClaims(namespace)
+	}
+	panic("uninitialized listener")
+}
+
+// recordError during the processing of the objects
+func (b *BucketAccessListener) recordError(subject runtime.Object, eventtype, reason string, err error) error {
+	if b.eventRecorder == nil {
+		return err
+	}
+	b.eventRecorder.Event(subject, eventtype, reason, err.Error())
+
+	return err
+}
@@ -0,0 +1,167 @@
+package bucketaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-api/controller/events"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+var goldAccessClass = v1alpha1.BucketAccessClass{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "accessclassgold",
+	},
+	DriverName:         "sample.cosi.driver",
+	AuthenticationType: v1alpha1.AuthenticationTypeKey,
+}
+
+var boundBucketClaim = v1alpha1.BucketClaim{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "bucketclaim1",
+		Namespace: "default",
+		UID:       "12345-67890",
+	},
+	Spec: v1alpha1.BucketClaimSpec{
+		BucketClassName: "classgold",
+		Protocols:       []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+	},
+	Status: v1alpha1.BucketClaimStatus{
+		BucketName:  "classgold12345-67890",
+		BucketReady: true,
+	},
+}
+
+var bucketAccess1 = v1alpha1.BucketAccess{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "bucketaccess1",
+		Namespace: "default",
+		UID:       "abcde-fghijk",
+	},
+	Spec: v1alpha1.BucketAccessSpec{
+		BucketClaimName:       "bucketclaim1",
+		BucketAccessClassName: "accessclassgold",
+		CredentialsSecretName: "bucketaccess1-creds",
+		Protocol:              v1alpha1.ProtocolS3,
+	},
+}
+
+// Test validation of bucket accesses and the events it records
+func TestValidateBucketAccess(t *testing.T) {
+	t.Parallel()
+
+	unboundBucketClaim := boundBucketClaim.DeepCopy()
+	unboundBucketClaim.Status = v1alpha1.BucketClaimStatus{}
+
+	for _, tc := range []struct {
+		name          string
+		objects       []runtime.Object
+		bucketAccess  func() *v1alpha1.BucketAccess
+		expectedErr   func(error) bool
+		expectedEvent string
+	}{
+		{
+			name:         "Valid",
+			objects:      []runtime.Object{&goldAccessClass, &boundBucketClaim},
+			bucketAccess: bucketAccess1.DeepCopy,
+			expectedErr:  func(err error) bool { return err == nil },
+		},
+		{
+			name:         "BucketAccessClassNotFound",
+			objects:      []runtime.Object{&boundBucketClaim},
+			bucketAccess: bucketAccess1.DeepCopy,
+			expectedErr:  kubeerrors.IsNotFound,
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				events.FailedGrantAccess,
+				"bucketaccessclasses.objectstorage.k8s.io \"accessclassgold\" not found"),
+		},
+		{
+			name:    "BucketClaimInOtherNamespace",
+			objects: []runtime.Object{&goldAccessClass, &boundBucketClaim},
+			bucketAccess: func() *v1alpha1.BucketAccess {
+				bucketAccess := bucketAccess1.DeepCopy()
+				bucketAccess.Namespace = "other"
+				return bucketAccess
+			},
+			expectedErr: kubeerrors.IsNotFound,
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				events.FailedGrantAccess,
+				"bucketclaims.objectstorage.k8s.io \"bucketclaim1\" not found"),
+		},
+		{
+			name:         "BucketClaimNotBound",
+			objects:      []runtime.Object{&goldAccessClass, unboundBucketClaim},
+			bucketAccess: bucketAccess1.DeepCopy,
+			expectedErr: func(err error) bool {
+				return errors.Is(err, util.ErrBucketClaimNotBound)
+			},
+			expectedEvent: newEvent(
+				v1.EventTypeNormal,
+				events.WaitingForBucket,
+				util.ErrBucketClaimNotBound.Error()),
+		},
+		{
+			name:    "ProtocolMismatch",
+			objects: []runtime.Object{&goldAccessClass, &boundBucketClaim},
+			bucketAccess: func() *v1alpha1.BucketAccess {
+				bucketAccess := bucketAccess1.DeepCopy()
+				bucketAccess.Spec.Protocol = v1alpha1.ProtocolAzure
+				return bucketAccess
+			},
+			expectedErr: func(err error) bool {
+				return errors.Is(err, util.ErrUnsupportedProtocol)
+			},
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				events.FailedGrantAccess,
+				fmt.Sprintf("%s: \"Azure\" not in [S3]", util.ErrUnsupportedProtocol)),
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fakebucketclientset.NewSimpleClientset(tc.objects...)
+			kubeClient := fakekubeclientset.NewSimpleClientset()
+			eventRecorder := record.NewFakeRecorder(1)
+
+			listener := NewBucketAccessListener()
+			listener.InitializeKubeClient(kubeClient)
+			listener.InitializeBucketClient(client)
+			listener.InitializeEventRecorder(eventRecorder)
+
+			err := listener.Add(context.TODO(), tc.bucketAccess())
+			if !tc.expectedErr(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+
+			select {
+			case event := <-eventRecorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected %q got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("no event after trigger")
+				}
+			}
+		})
+	}
+}
+
+func newEvent(eventType, reason, message string) string {
+	return fmt.Sprintf("%s %s %s", eventType, reason, message)
+}
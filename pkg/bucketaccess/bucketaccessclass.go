@@ -0,0 +1,194 @@
+package bucketaccess
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	bucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// BucketAccessClassListener is a resource handler for bucket access class
+// objects. It protects bucketAccessClasses that are in use from deletion.
+type BucketAccessClassListener struct {
+	accesses *BucketAccessListener
+}
+
+// NewBucketAccessClassListener returns a BucketAccessClassListener that acts
+// through the given BucketAccessListener
+func NewBucketAccessClassListener(accesses *BucketAccessListener) *BucketAccessClassListener {
+	return &BucketAccessClassListener{
+		accesses: accesses,
+	}
+}
+
+// Add protects a new bucketAccessClass
+func (b *BucketAccessClassListener) Add(ctx context.Context, bucketAccessClass *v1alpha1.BucketAccessClass) (err error) {
+	klog.V(3).InfoS("Add BucketAccessClass",
+		"name", bucketAccessClass.ObjectMeta.Name)
+	done := metrics.StartReconcile("BucketAccessClass", "Add")
+	defer func() { done(err) }()
+
+	return b.accesses.syncBucketAccessClassProtection(ctx, bucketAccessClass.ObjectMeta.Name)
+}
+
+// Update protects an updated bucketAccessClass, and releases it once it is
+// being deleted and no longer in use
+func (b *BucketAccessClassListener) Update(ctx context.Context, old, new *v1alpha1.BucketAccessClass) (err error) {
+	klog.V(3).InfoS("Update BucketAccessClass",
+		"name", old.Name)
+	done := metrics.StartReconcile("BucketAccessClass", "Update")
+	defer func() { done(err) }()
+
+	return b.accesses.syncBucketAccessClassProtection(ctx, new.ObjectMeta.Name)
+}
+
+// Delete processes a deleted bucketAccessClass
+func (b *BucketAccessClassListener) Delete(ctx context.Context, bucketAccessClass *v1alpha1.BucketAccessClass) error {
+	klog.V(3).InfoS("Delete BucketAccessClass",
+		"name", bucketAccessClass.ObjectMeta.Name)
+	done := metrics.StartReconcile("BucketAccessClass", "Delete")
+	defer done(nil)
+
+	return nil
+}
+
+// InitializeKubeClient initializes the kubernetes client
+func (b *BucketAccessClassListener) InitializeKubeClient(k kubeclientset.Interface) {
+	b.accesses.InitializeKubeClient(k)
+}
+
+// InitializeBucketClient initializes the object storage bucket client
+func (b *BucketAccessClassListener) InitializeBucketClient(bc bucketclientset.Interface) {
+	b.accesses.InitializeBucketClient(bc)
+}
+
+// InitializeEventRecorder initializes the event recorder
+func (b *BucketAccessClassListener) InitializeEventRecorder(er record.EventRecorder) {
+	b.accesses.InitializeEventRecorder(er)
+}
+
+// protectBucketAccessClass adds the protection finalizer to bucketAccessClass,
+// unless it has it already or is being deleted
+func (b *BucketAccessListener) protectBucketAccessClass(ctx context.Context, bucketAccessClass *v1alpha1.BucketAccessClass) error {
+	if controllerutil.ContainsFinalizer(bucketAccessClass, util.BucketAccessClassFinalizer) || !bucketAccessClass.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+
+	return b.patchBucketAccessClassFinalizers(ctx, bucketAccessClass, func(bucketAccessClass *v1alpha1.BucketAccessClass) {
+		if bucketAccessClass.GetDeletionTimestamp().IsZero() {
+			controllerutil.AddFinalizer(bucketAccessClass, util.BucketAccessClassFinalizer)
+		}
+	})
+}
+
+// syncBucketAccessClassProtection keeps the protection finalizer on the named
+// bucketAccessClass while bucketAccesses reference it, and removes it once the
+// last reference is gone. A bucketAccessClass whose deletion is blocked gets
+// an event listing the bucketAccesses referencing it.
+func (b *BucketAccessListener) syncBucketAccessClassProtection(ctx context.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	bucketAccessClass, err := b.bucketAccessClasses().Get(ctx, name, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	deleting := !bucketAccessClass.GetDeletionTimestamp().IsZero()
+	protected := controllerutil.ContainsFinalizer(bucketAccessClass, util.BucketAccessClassFinalizer)
+
+	bucketAccesses, err := b.bucketAccessesForClass(ctx, name)
+	if err != nil {
+		return err
+	}
+	// The cache may still hold bucketAccesses that were just deleted, so a
+	// blocked deletion is confirmed against the API server
+	if deleting && protected && len(bucketAccesses) > 0 && b.listers != nil {
+		bucketAccesses, err = b.listBucketAccessesForClass(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(bucketAccesses) == 0:
+		if !protected {
+			return nil
+		}
+		err = b.patchBucketAccessClassFinalizers(ctx, bucketAccessClass, func(bucketAccessClass *v1alpha1.BucketAccessClass) {
+			controllerutil.RemoveFinalizer(bucketAccessClass, util.BucketAccessClassFinalizer)
+		})
+		if err != nil {
+			return err
+		}
+		klog.V(3).InfoS("Removed BucketAccessClass protection",
+			"name", name)
+		return nil
+	case deleting:
+		keys := make([]string, 0, len(bucketAccesses))
+		for _, bucketAccess := range bucketAccesses {
+			keys = append(keys, bucketAccess.ObjectMeta.Namespace+"/"+bucketAccess.ObjectMeta.Name)
+		}
+		sort.Strings(keys)
+		if b.eventRecorder != nil {
+			b.eventRecorder.Eventf(bucketAccessClass, v1.EventTypeWarning, util.BucketAccessClassInUse,
+				"BucketAccessClass is in use by %d bucket accesses [%s]", len(bucketAccesses), util.JoinNames(keys))
+		}
+		return nil
+	default:
+		return b.protectBucketAccessClass(ctx, bucketAccessClass)
+	}
+}
+
+// patchBucketAccessClassFinalizers applies mutate to a copy of
+// bucketAccessClass and writes its finalizers as a merge patch that only
+// applies to the resourceVersion mutate saw. On conflicts, mutate is applied
+// again to the latest version of the bucketAccessClass.
+func (b *BucketAccessListener) patchBucketAccessClassFinalizers(ctx context.Context, bucketAccessClass *v1alpha1.BucketAccessClass, mutate func(*v1alpha1.BucketAccessClass)) error {
+	current := bucketAccessClass
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		modified := current.DeepCopy()
+		mutate(modified)
+		if equality.Semantic.DeepEqual(current.ObjectMeta.Finalizers, modified.ObjectMeta.Finalizers) {
+			return nil
+		}
+
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"finalizers":      modified.ObjectMeta.Finalizers,
+				"resourceVersion": current.ObjectMeta.ResourceVersion,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = b.bucketAccessClasses().Patch(ctx, current.ObjectMeta.Name, types.MergePatchType, patch,
+			metav1.PatchOptions{FieldManager: util.FieldManager})
+		if kubeerrors.IsConflict(err) {
+			latest, getErr := b.bucketAccessClasses().Get(ctx, current.ObjectMeta.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			current = latest
+		}
+		return err
+	})
+}
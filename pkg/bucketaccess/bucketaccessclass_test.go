@@ -0,0 +1,120 @@
+package bucketaccess
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	bucketinformers "sigs.k8s.io/container-object-storage-interface-api/client/informers/externalversions"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Test protecting a bucketAccessClass from deletion while bucketAccesses
+// reference it
+func TestBucketAccessClassProtection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bucketAccessClass := goldAccessClass.DeepCopy()
+	bucketAccess := bucketAccess1.DeepCopy()
+	client := fakebucketclientset.NewSimpleClientset(bucketAccessClass, bucketAccess)
+	eventRecorder := record.NewFakeRecorder(10)
+
+	accessListener := NewBucketAccessListener()
+	listener := NewBucketAccessClassListener(accessListener)
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	if err := listener.Add(ctx, bucketAccessClass); err != nil {
+		t.Fatalf("Error occurred when adding BucketAccessClass: %v", err)
+	}
+	protected, err := client.ObjectstorageV1alpha1().BucketAccessClasses().Get(ctx, bucketAccessClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketAccessClass: %v", err)
+	}
+	if !controllerutil.ContainsFinalizer(protected, util.BucketAccessClassFinalizer) {
+		t.Fatalf("Expected BucketAccessClass in use to be protected got finalizers %v", protected.Finalizers)
+	}
+
+	deleting := protected.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	deleting, err = client.ObjectstorageV1alpha1().BucketAccessClasses().Update(ctx, deleting, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when updating BucketAccessClass: %v", err)
+	}
+	if err := listener.Update(ctx, protected, deleting); err != nil {
+		t.Fatalf("Error occurred when updating BucketAccessClass: %v", err)
+	}
+
+	select {
+	case event := <-eventRecorder.Events:
+		if !strings.Contains(event, util.BucketAccessClassInUse) || !strings.Contains(event, "default/bucketaccess1") {
+			t.Errorf("Expected event listing the BucketAccess got %q", event)
+		}
+	default:
+		t.Errorf("Expected an event on the BucketAccessClass whose deletion is blocked")
+	}
+
+	err = client.ObjectstorageV1alpha1().BucketAccesses(bucketAccess.Namespace).Delete(ctx, bucketAccess.Name, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when deleting BucketAccess: %v", err)
+	}
+	if err := accessListener.Delete(ctx, bucketAccess); err != nil {
+		t.Fatalf("Error occurred when deleting BucketAccess: %v", err)
+	}
+
+	released, err := client.ObjectstorageV1alpha1().BucketAccessClasses().Get(ctx, bucketAccessClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketAccessClass: %v", err)
+	}
+	if controllerutil.ContainsFinalizer(released, util.BucketAccessClassFinalizer) {
+		t.Errorf("Expected BucketAccessClass to be released after its last BucketAccess got finalizers %v", released.Finalizers)
+	}
+}
+
+// Test that bucketAccessClass protection reads bucketAccesses from the
+// informer cache
+func TestBucketAccessClassInformers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset(goldAccessClass.DeepCopy(), bucketAccess1.DeepCopy())
+	factory := bucketinformers.NewSharedInformerFactory(client, 0)
+	informers := factory.Objectstorage().V1alpha1()
+	if err := AddIndexers(informers); err != nil {
+		t.Fatalf("Error occurred when adding indexers: %v", err)
+	}
+
+	listener := NewBucketAccessClassListener(NewBucketAccessListener(WithInformers(informers)))
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(record.NewFakeRecorder(10))
+
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	client.ClearActions()
+
+	if err := listener.Add(ctx, goldAccessClass.DeepCopy()); err != nil {
+		t.Fatalf("Error occurred when adding BucketAccessClass: %v", err)
+	}
+	protected, err := client.ObjectstorageV1alpha1().BucketAccessClasses().Get(ctx, goldAccessClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketAccessClass: %v", err)
+	}
+	if !controllerutil.ContainsFinalizer(protected, util.BucketAccessClassFinalizer) {
+		t.Errorf("Expected BucketAccessClass in use to be protected got finalizers %v", protected.Finalizers)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "bucketaccesses" {
+			t.Errorf("unexpected live list of bucketaccesses")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package bucketaccess
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	objectstorageinformers "sigs.k8s.io/container-object-storage-interface-api/client/informers/externalversions/objectstorage/v1alpha1"
+)
+
+// BucketAccessesByClassIndex indexes bucketAccesses by the name of their
+// bucketAccessClass
+const BucketAccessesByClassIndex = "bucketAccessesByClass"
+
+// listers are the informer caches a BucketAccessListener reads from
+type listers struct {
+	bucketAccessIndexer cache.Indexer
+}
+
+// AddIndexers adds the indexes the BucketAccessListener looks objects up by
+// to the shared informers. It must be called before the informers are started.
+func AddIndexers(informers objectstorageinformers.Interface) error {
+	return informers.BucketAccesses().Informer().AddIndexers(cache.Indexers{
+		BucketAccessesByClassIndex: func(obj interface{}) ([]string, error) {
+			bucketAccess, ok := obj.(*v1alpha1.BucketAccess)
+			if !ok || bucketAccess.Spec.BucketAccessClassName == "" {
+				return nil, nil
+			}
+			return []string{bucketAccess.Spec.BucketAccessClassName}, nil
+		},
+	})
+}
+
+// WithInformers makes the listener read bucketAccesses from the cache of the
+// shared informers, which must have the indexes added by AddIndexers
+func WithInformers(informers objectstorageinformers.Interface) Option {
+	return func(b *BucketAccessListener) {
+		b.listers = &listers{
+			bucketAccessIndexer: informers.BucketAccesses().Informer().GetIndexer(),
+		}
+	}
+}
+
+// bucketAccessesForClass returns the cached bucketAccesses referencing the
+// named bucketAccessClass. Without informers they are listed from the API
+// server.
+func (b *BucketAccessListener) bucketAccessesForClass(ctx context.Context, name string) ([]*v1alpha1.BucketAccess, error) {
+	if b.listers == nil {
+		return b.listBucketAccessesForClass(ctx, name)
+	}
+
+	objs, err := b.listers.bucketAccessIndexer.ByIndex(BucketAccessesByClassIndex, name)
+	if err != nil {
+		return nil, err
+	}
+	bucketAccesses := make([]*v1alpha1.BucketAccess, 0, len(objs))
+	for _, obj := range objs {
+		bucketAccess, ok := obj.(*v1alpha1.BucketAccess)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %T in bucket access index", obj)
+		}
+		bucketAccesses = append(bucketAccesses, bucketAccess.DeepCopy())
+	}
+	return bucketAccesses, nil
+}
+
+// listBucketAccessesForClass returns the bucketAccesses referencing the named
+// bucketAccessClass from the API server
+func (b *BucketAccessListener) listBucketAccessesForClass(ctx context.Context, name string) ([]*v1alpha1.BucketAccess, error) {
+	list, err := b.bucketAccesses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	bucketAccesses := []*v1alpha1.BucketAccess{}
+	for i := range list.Items {
+		if list.Items[i].Spec.BucketAccessClassName == name {
+			bucketAccesses = append(bucketAccesses, &list.Items[i])
+		}
+	}
+	return bucketAccesses, nil
+}
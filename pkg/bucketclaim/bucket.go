@@ -0,0 +1,155 @@
+package bucketclaim
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	bucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// BucketListener is a resource handler for bucket objects. It mirrors the
+// status of each bucket onto the bucketClaim it is bound to, and keeps the
+// bucketClass of each bucket protected from deletion.
+type BucketListener struct {
+	claims *BucketClaimListener
+}
+
+// NewBucketListener returns a BucketListener that updates bucketClaims through
+// the given BucketClaimListener
+func NewBucketListener(claims *BucketClaimListener) *BucketListener {
+	return &BucketListener{
+		claims: claims,
+	}
+}
+
+// Add mirrors the status of a bucket onto its bucketClaim and protects its
+// bucketClass
+func (b *BucketListener) Add(ctx context.Context, bucket *v1alpha1.Bucket) (err error) {
+	klog.V(3).InfoS("Add Bucket",
+		"name", bucket.ObjectMeta.Name)
+	done := metrics.StartReconcile("Bucket", "Add")
+	defer func() { done(err) }()
+
+	err = b.claims.syncBucketStatus(ctx, bucket, bucket.Status.BucketReady)
+	if err != nil {
+		return err
+	}
+	return b.claims.syncBucketClassProtection(ctx, bucket.Spec.BucketClassName)
+}
+
+// Update mirrors the status of a bucket onto its bucketClaim
+func (b *BucketListener) Update(ctx context.Context, old, new *v1alpha1.Bucket) (err error) {
+	klog.V(3).InfoS("Update Bucket",
+		"name", old.Name)
+	done := metrics.StartReconcile("Bucket", "Update")
+	defer func() { done(err) }()
+
+	return b.claims.syncBucketStatus(ctx, new, new.Status.BucketReady && new.GetDeletionTimestamp().IsZero())
+}
+
+// Delete marks the bucketClaim of a deleted bucket as not ready, and releases
+// its bucketClass if it was the last reference to it
+func (b *BucketListener) Delete(ctx context.Context, bucket *v1alpha1.Bucket) (err error) {
+	klog.V(3).InfoS("Delete Bucket",
+		"name", bucket.ObjectMeta.Name)
+	done := metrics.StartReconcile("Bucket", "Delete")
+	defer func() { done(err) }()
+
+	err = b.claims.syncBucketStatus(ctx, bucket, false)
+	if err != nil {
+		return err
+	}
+	return b.claims.syncBucketClassProtection(ctx, bucket.Spec.BucketClassName)
+}
+
+// InitializeKubeClient initializes the kubernetes client
+func (b *BucketListener) InitializeKubeClient(k kubeclientset.Interface) {
+	b.claims.InitializeKubeClient(k)
+}
+
+// InitializeBucketClient initializes the object storage bucket client
+func (b *BucketListener) InitializeBucketClient(bc bucketclientset.Interface) {
+	b.claims.InitializeBucketClient(bc)
+}
+
+// InitializeEventRecorder initializes the event recorder
+func (b *BucketListener) InitializeEventRecorder(er record.EventRecorder) {
+	b.claims.InitializeEventRecorder(er)
+}
+
+// syncBucketStatus copies the readiness and ID of a bucket onto the status of
+// the bucketClaim it is bound to
+func (b *BucketClaimListener) syncBucketStatus(ctx context.Context, bucket *v1alpha1.Bucket, ready bool) error {
+	ref := bucket.Spec.BucketClaim
+	if ref == nil || ref.UID == "" {
+		return nil
+	}
+
+	bucketClaim, err := b.getBucketClaim(ctx, ref.Namespace, ref.Name)
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		klog.V(3).ErrorS(err, "Get BucketClaim error", "name", ref.Name, "ns", ref.Namespace)
+		return err
+	}
+
+	if bucketClaim.ObjectMeta.UID != ref.UID || !bucketClaim.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+
+	if bucketClaim.Status.BucketName == "" {
+		// The bucket was created but the bucketClaim status has not been
+		// written yet, retry once provisioning has recorded the bucket name.
+		return util.ErrWaitingForBucketClaimStatus
+	}
+	if bucketClaim.Status.BucketName != bucket.ObjectMeta.Name {
+		return nil
+	}
+
+	bucketID := bucket.Status.BucketID
+	if bucketClaim.ObjectMeta.Annotations[util.BucketIDAnnotation] != bucketID {
+		bucketClaim, err = b.patchBucketClaim(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+			if bucketClaim.ObjectMeta.Annotations == nil {
+				bucketClaim.ObjectMeta.Annotations = map[string]string{}
+			}
+			bucketClaim.ObjectMeta.Annotations[util.BucketIDAnnotation] = bucketID
+			return nil
+		})
+		if err != nil {
+			klog.V(3).ErrorS(err, "Failed to update bucket ID of BucketClaim", "name", ref.Name, "ns", ref.Namespace)
+			return err
+		}
+	}
+
+	if bucketClaim.Status.BucketReady == ready {
+		return nil
+	}
+
+	bucketClaim, err = b.patchBucketClaimStatus(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+		bucketClaim.Status.BucketReady = ready
+		return nil
+	})
+	if err != nil {
+		klog.V(3).ErrorS(err, "Failed to update status of BucketClaim", "name", ref.Name, "ns", ref.Namespace)
+		return err
+	}
+
+	if ready {
+		b.recordEvent(bucketClaim, v1.EventTypeNormal, util.BucketProvisioned,
+			"Bucket %s is ready", bucket.ObjectMeta.Name)
+	}
+
+	klog.V(3).InfoS("Synced BucketClaim status from Bucket",
+		"bucketClaim", bucketClaim.ObjectMeta.Name,
+		"ns", bucketClaim.ObjectMeta.Namespace,
+		"bucket", bucket.ObjectMeta.Name,
+		"ready", ready)
+	return nil
+}
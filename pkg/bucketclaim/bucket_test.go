@@ -0,0 +1,97 @@
+package bucketclaim
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	types "sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test mirroring bucket readiness onto the bound bucketClaim
+func TestBucketReadyMirrored(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	kubeClient := fakekubeclientset.NewSimpleClientset()
+	eventRecorder := record.NewFakeRecorder(3)
+
+	claimListener := NewBucketClaimListener()
+	listener := NewBucketListener(claimListener)
+	listener.InitializeKubeClient(kubeClient)
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	bucketclass, err := util.CreateBucketClass(ctx, client, &goldClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+
+	claimListener.Add(ctx, bucketClaim)
+
+	bucketList := util.GetBuckets(ctx, client, 1)
+	defer util.DeleteObjects(ctx, client, *bucketClaim, *bucketclass, bucketList.Items)
+	if len(bucketList.Items) != 1 {
+		t.Fatalf("Expecting a single Bucket created but found %v", len(bucketList.Items))
+	}
+
+	bucket := bucketList.Items[0].DeepCopy()
+	readyBucket := bucket.DeepCopy()
+	readyBucket.Status = types.BucketStatus{
+		BucketReady: true,
+		BucketID:    "backend-bucket-id",
+	}
+
+	err = listener.Update(ctx, bucket, readyBucket)
+	if err != nil {
+		t.Fatalf("Error occurred when updating Bucket: %v", err)
+	}
+
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if !bucketClaim.Status.BucketReady {
+		t.Errorf("Expecting BucketClaim to be ready")
+	}
+	if id := bucketClaim.Annotations[util.BucketIDAnnotation]; id != "backend-bucket-id" {
+		t.Errorf("Expecting BucketClaim bucket ID %q but found %q", "backend-bucket-id", id)
+	}
+
+	expectedEvent := newEvent(
+		v1.EventTypeNormal,
+		util.BucketProvisioned,
+		"Bucket "+bucket.Name+" is ready")
+	select {
+	case event := <-eventRecorder.Events:
+		if event != expectedEvent {
+			t.Errorf("expected %s got %s", expectedEvent, event)
+		}
+	default:
+		t.Errorf("no event after bucket became ready")
+	}
+
+	err = listener.Delete(ctx, readyBucket)
+	if err != nil {
+		t.Fatalf("Error occurred when deleting Bucket: %v", err)
+	}
+
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if bucketClaim.Status.BucketReady {
+		t.Errorf("Expecting BucketClaim to not be ready after its Bucket is deleted")
+	}
+}
@@ -3,6 +3,7 @@ package bucketclaim
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	v1 "k8s.io/api/core/v1"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -15,6 +16,7 @@ import (
 	bucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned"
 	objectstoragev1alpha1 "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/typed/objectstorage/v1alpha1"
 	"sigs.k8s.io/container-object-storage-interface-api/controller/events"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/metrics"
 	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -25,21 +27,61 @@ type BucketClaimListener struct {
 
 	kubeClient   kubeclientset.Interface
 	bucketClient bucketclientset.Interface
+	listers      *listers
+
+	// settingsLock guards the settings that can be changed while the
+	// listener is running
+	settingsLock           sync.RWMutex
+	bucketNamer            BucketNamer
+	defaultBucketClassName string
+}
+
+// Option configures a BucketClaimListener
+type Option func(*BucketClaimListener)
+
+// WithBucketNamer sets the BucketNamer used for bucketClasses that do not
+// select a naming strategy of their own
+func WithBucketNamer(namer BucketNamer) Option {
+	return func(b *BucketClaimListener) {
+		b.bucketNamer = namer
+	}
 }
 
-func NewBucketClaimListener() *BucketClaimListener {
-	return &BucketClaimListener{}
+// WithDefaultBucketClassName sets the bucketClass used for bucketClaims that
+// do not name one when neither their namespace nor any bucketClass is marked
+// as the default
+func WithDefaultBucketClassName(name string) Option {
+	return func(b *BucketClaimListener) {
+		b.defaultBucketClassName = name
+	}
+}
+
+func NewBucketClaimListener(opts ...Option) *BucketClaimListener {
+	b := &BucketClaimListener{
+		bucketNamer: concatNamer{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
 // Add creates a bucket in response to a bucketClaim
-func (b *BucketClaimListener) Add(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) error {
+func (b *BucketClaimListener) Add(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) (err error) {
 	klog.V(3).InfoS("Add BucketClaim",
 		"name", bucketClaim.ObjectMeta.Name,
 		"ns", bucketClaim.ObjectMeta.Namespace,
 		"bucketClass", bucketClaim.Spec.BucketClassName,
 	)
+	done := metrics.StartReconcile("BucketClaim", "Add")
+	defer func() { done(err) }()
+	metrics.SetBucketClaimState(bucketClaimKey(bucketClaim), bucketClaimState(bucketClaim))
 
-	err := b.provisionBucketClaimOperation(ctx, bucketClaim)
+	if !bucketClaim.GetDeletionTimestamp().IsZero() {
+		return b.deleteBucketClaimOperation(ctx, bucketClaim)
+	}
+
+	err = b.provisionBucketClaimOperation(ctx, bucketClaim)
 	if err != nil {
 		switch err {
 		case util.ErrInvalidBucketClass:
@@ -69,25 +111,39 @@ func (b *BucketClaimListener) Add(ctx context.Context, bucketClaim *v1alpha1.Buc
 }
 
 // update processes any updates  made to the bucket request
-func (b *BucketClaimListener) Update(ctx context.Context, old, new *v1alpha1.BucketClaim) error {
+func (b *BucketClaimListener) Update(ctx context.Context, old, new *v1alpha1.BucketClaim) (err error) {
 	klog.V(3).InfoS("Update BucketClaim",
 		"name", old.Name,
 		"ns", old.Namespace)
+	done := metrics.StartReconcile("BucketClaim", "Update")
+	defer func() { done(err) }()
+	metrics.SetBucketClaimState(bucketClaimKey(new), bucketClaimState(new))
 
 	bucketClaim := new.DeepCopy()
 
 	if !new.GetDeletionTimestamp().IsZero() {
-		if controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer) {
-			bucketName := bucketClaim.Status.BucketName
-			err := b.buckets().Delete(ctx, bucketName, metav1.DeleteOptions{})
-			if err != nil {
-				klog.V(3).ErrorS(err, "Error deleting bucket",
-					"bucket", bucketName,
-					"bucketClaim", bucketClaim.ObjectMeta.Name)
-				return b.recordError(bucketClaim, v1.EventTypeWarning, events.FailedDeleteBucket, err)
-			}
-
-			klog.V(5).Infof("Successfully deleted bucket: %s from bucketClaim: %s", bucketName, bucketClaim.ObjectMeta.Name)
+		err = b.deleteBucketClaimOperation(ctx, bucketClaim)
+		if err != nil {
+			return err
+		}
+	} else if bucketClaim.Status.BucketName == "" {
+		// An update replaces a failed Add in the queue, provisioning is
+		// retried here, e.g. once the missing bucketClass was created.
+		err = b.provisionBucketClaimOperation(ctx, bucketClaim)
+		if err != nil {
+			return err
+		}
+	} else if err = ValidateBucketClaimUpdate(old, new); err != nil {
+		// The bucket stays bound to the bucketClaim it was provisioned or
+		// bound for, so the bucketClaim is changed back to match it.
+		err = b.revertBucketClaimSpec(ctx, old, bucketClaim, err)
+		if err != nil {
+			return err
+		}
+	} else if protocolsChanged(old.Spec.Protocols, new.Spec.Protocols) {
+		err = b.updateBucketProtocolsOperation(ctx, bucketClaim)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -102,29 +158,55 @@ func (b *BucketClaimListener) Delete(ctx context.Context, bucketClaim *v1alpha1.
 	klog.V(3).InfoS("Delete BucketClaim",
 		"name", bucketClaim.ObjectMeta.Name,
 		"ns", bucketClaim.ObjectMeta.Namespace)
+	done := metrics.StartReconcile("BucketClaim", "Delete")
+	defer done(nil)
+	metrics.ForgetBucketClaim(bucketClaimKey(bucketClaim))
 
+	// Only logged, the bucketClass protection is synced again on the next
+	// event of the bucketClass
+	err := b.syncBucketClassProtection(ctx, bucketClaim.Spec.BucketClassName)
+	if err != nil {
+		klog.V(3).ErrorS(err, "Failed to sync BucketClass protection",
+			"bucketClass", bucketClaim.Spec.BucketClassName,
+			"bucketClaim", bucketClaim.ObjectMeta.Name)
+	}
 	return nil
 }
 
 // provisionBucketClaimOperation attempts to provision a bucket for a given bucketClaim.
 //
+// The bucketClaim gets its finalizer and the reserved bucket name before the
+// bucket is created or bound, and its status last, so that each attempt can
+// resume where an interrupted one stopped.
+//
 // Return values
 //   - nil - BucketClaim successfully processed
 //   - ErrInvalidBucketClass - BucketClass does not exist          [requeue'd with exponential backoff]
+//   - ErrMultipleDefaultBucketClasses - BucketClass cannot be defaulted [requeue'd with exponential backoff]
 //   - ErrBucketAlreadyExists - BucketClaim already processed
+//   - ErrBucketAlreadyBound - Existing Bucket belongs to another BucketClaim [requeue'd with exponential backoff]
+//   - ErrNoProtocols, ErrUnknownProtocol - BucketClaim protocols are invalid  [requeue'd with exponential backoff]
+//   - ErrProtocolMismatch - BucketClass or existing Bucket cannot serve the protocols [requeue'd with exponential backoff]
 //   - non-nil err - Internal error                                [requeue'd with exponential backoff]
-func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context, inputBucketClaim *v1alpha1.BucketClaim) error {
+func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context, inputBucketClaim *v1alpha1.BucketClaim) (err error) {
 	bucketClaim := inputBucketClaim.DeepCopy()
 	if bucketClaim.Status.BucketReady {
 		return util.ErrBucketAlreadyExists
 	}
 
 	var bucketName string
-	var err error
+	var bucketReady bool
+	metricsClassName, metricsDriverName := bucketClaim.Spec.BucketClassName, ""
+	defer func() { metrics.RecordProvision(metricsClassName, metricsDriverName, err) }()
+
+	err = validateClaimProtocols(bucketClaim)
+	if err != nil {
+		return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+	}
 
 	if bucketClaim.Spec.ExistingBucketName != "" {
 		bucketName = bucketClaim.Spec.ExistingBucketName
-		bucket, err := b.buckets().Get(ctx, bucketName, metav1.GetOptions{})
+		bucket, err := b.getBucket(ctx, bucketName)
 		if kubeerrors.IsNotFound(err) {
 			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		} else if err != nil {
@@ -132,17 +214,54 @@ func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context,
 			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		}
 
-		bucket.Spec.BucketClaim = &v1.ObjectReference{
-			Name:      bucketClaim.ObjectMeta.Name,
-			Namespace: bucketClaim.ObjectMeta.Namespace,
-			UID:       bucketClaim.ObjectMeta.UID,
+		metricsClassName, metricsDriverName = bucket.Spec.BucketClassName, bucket.Spec.DriverName
+
+		err = checkBucketBinding(bucket, bucketClaim)
+		if err != nil {
+			klog.V(3).ErrorS(err, "Existing bucket cannot be bound",
+				"bucket", bucket.ObjectMeta.Name,
+				"bucketClaim", bucketClaim.ObjectMeta.Name)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		}
 
-		protocolCopy := make([]v1alpha1.Protocol, len(bucketClaim.Spec.Protocols))
-		copy(protocolCopy, bucketClaim.Spec.Protocols)
+		err = checkBucketProtocols(bucketClaim, bucket)
+		if err != nil {
+			klog.V(3).ErrorS(err, "Existing bucket protocols do not match",
+				"bucket", bucket.ObjectMeta.Name,
+				"bucketClaim", bucketClaim.ObjectMeta.Name)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, util.ProtocolMismatch, err)
+		}
 
-		bucket.Spec.Protocols = protocolCopy
-		_, err = b.buckets().Update(ctx, bucket, metav1.UpdateOptions{})
+		bucketClaim, err = b.protectBucketClaim(ctx, bucketClaim, "")
+		if err != nil {
+			klog.V(3).ErrorS(err, "Failed to add finalizer BucketClaim", "name", inputBucketClaim.ObjectMeta.Name)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+		}
+
+		_, err = b.patchBucket(ctx, bucket, func(bucket *v1alpha1.Bucket) error {
+			// The bucket may have been bound by someone else since it
+			// was checked.
+			if err := checkBucketBinding(bucket, bucketClaim); err != nil {
+				return err
+			}
+
+			bucket.Spec.BucketClaim = &v1.ObjectReference{
+				Name:      bucketClaim.ObjectMeta.Name,
+				Namespace: bucketClaim.ObjectMeta.Namespace,
+				UID:       bucketClaim.ObjectMeta.UID,
+			}
+			delete(bucket.ObjectMeta.Annotations, util.BucketReleasedAnnotation)
+
+			// Protocols declared by the bucket describe what the backend serves,
+			// only fill them in when the bucket does not declare any.
+			if len(bucket.Spec.Protocols) == 0 {
+				protocolCopy := make([]v1alpha1.Protocol, len(bucketClaim.Spec.Protocols))
+				copy(protocolCopy, bucketClaim.Spec.Protocols)
+
+				bucket.Spec.Protocols = protocolCopy
+			}
+			return nil
+		})
 		if err != nil {
 			klog.V(3).ErrorS(err, "Error updating existing bucket",
 				"bucket", bucket.ObjectMeta.Name,
@@ -150,15 +269,14 @@ func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context,
 			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		}
 
-		bucketClaim.Status.BucketName = bucketName
-		bucketClaim.Status.BucketReady = true
+		bucketReady = true
 	} else {
-		bucketClassName := bucketClaim.Spec.BucketClassName
-		if bucketClassName == "" {
-			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, util.ErrInvalidBucketClass)
+		bucketClassName, err := b.resolveBucketClassName(ctx, bucketClaim)
+		if err != nil {
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		}
 
-		bucketClass, err := b.bucketClasses().Get(ctx, bucketClassName, metav1.GetOptions{})
+		bucketClass, err := b.getBucketClass(ctx, bucketClassName)
 		if kubeerrors.IsNotFound(err) {
 			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		} else if err != nil {
@@ -166,7 +284,53 @@ func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context,
 			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		}
 
-		bucketName = bucketClassName + string(bucketClaim.ObjectMeta.UID)
+		metricsClassName, metricsDriverName = bucketClassName, bucketClass.DriverName
+
+		err = b.protectBucketClass(ctx, bucketClass)
+		if err != nil {
+			klog.V(3).ErrorS(err, "Failed to protect BucketClass", "name", bucketClassName)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+		}
+
+		err = checkClassProtocols(bucketClaim, bucketClass)
+		if err != nil {
+			klog.V(3).ErrorS(err, "BucketClass protocols do not match",
+				"bucketClass", bucketClassName,
+				"bucketClaim", bucketClaim.ObjectMeta.Name)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, util.ProtocolMismatch, err)
+		}
+
+		bucketNamer, err := b.bucketNamerFor(bucketClass)
+		if err != nil {
+			klog.V(3).ErrorS(err, "Invalid bucket naming strategy", "bucketClass", bucketClassName)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+		}
+
+		// A bucket name reserved by an earlier attempt is kept, the bucket
+		// may already have been created under it.
+		bucketName = bucketClaim.ObjectMeta.Annotations[util.BucketNameAnnotation]
+		if bucketName == "" {
+			bucketName, err = bucketNamer.BucketName(bucketClaim, bucketClassName)
+			if err != nil {
+				klog.V(3).ErrorS(err, "Error generating bucket name", "bucketClass", bucketClassName)
+				return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+			}
+			bucketName, err = b.compliantBucketName(bucketClaim, bucketClassName, bucketNamer, bucketName)
+			if err != nil {
+				klog.V(3).ErrorS(err, "Generated bucket name breaks protocol naming rules", "bucketClass", bucketClassName)
+				return b.recordError(inputBucketClaim, v1.EventTypeWarning, util.InvalidBucketName, err)
+			}
+		}
+
+		// The finalizer and the reserved bucket name are written before the
+		// bucket is created, so that a bucket created by an interrupted
+		// attempt is found again by the next attempt or by the deletion
+		// of the bucketClaim.
+		bucketClaim, err = b.protectBucketClaim(ctx, bucketClaim, bucketName)
+		if err != nil {
+			klog.V(3).ErrorS(err, "Failed to reserve bucket name on BucketClaim", "name", inputBucketClaim.ObjectMeta.Name)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+		}
 
 		// create bucket
 		bucket := &v1alpha1.Bucket{}
@@ -175,7 +339,7 @@ func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context,
 		bucket.Status.BucketReady = false
 		bucket.Spec.BucketClassName = bucketClassName
 		bucket.Spec.DeletionPolicy = bucketClass.DeletionPolicy
-		bucket.Spec.Parameters = util.CopySS(bucketClass.Parameters)
+		bucket.Spec.Parameters = util.DriverParameters(bucketClass.Parameters)
 
 		bucket.Spec.BucketClaim = &v1.ObjectReference{
 			Name:      bucketClaim.ObjectMeta.Name,
@@ -188,38 +352,224 @@ func (b *BucketClaimListener) provisionBucketClaimOperation(ctx context.Context,
 
 		bucket.Spec.Protocols = protocolCopy
 		bucket, err = b.buckets().Create(ctx, bucket, metav1.CreateOptions{})
-		if err != nil && !kubeerrors.IsAlreadyExists(err) {
+		if kubeerrors.IsAlreadyExists(err) {
+			// Naming strategies that do not include the UID may collide,
+			// only reuse the bucket if it was created for this bucketClaim.
+			bucket, err = b.buckets().Get(ctx, bucketName, metav1.GetOptions{})
+			if err == nil && (bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != bucketClaim.ObjectMeta.UID) {
+				err = fmt.Errorf("%w: %q", util.ErrBucketNameConflict, bucketName)
+			}
+		}
+		if err != nil {
 			klog.V(3).ErrorS(err, "Error creationg bucket",
 				"bucket", bucketName,
 				"bucketClaim", bucketClaim.ObjectMeta.Name)
 			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 		}
 
-		bucketClaim.Status.BucketName = bucketName
-		bucketClaim.Status.BucketReady = false
+		bucketReady = bucket.Status.BucketReady
 	}
 
-	// Fetching the updated bucketClaim again, so that the update
-	// operation doesn't happen on an outdated version of the bucketClaim.
-	bucketClaim, err = b.bucketClaims(bucketClaim.ObjectMeta.Namespace).UpdateStatus(ctx, bucketClaim, metav1.UpdateOptions{})
+	_, err = b.patchBucketClaimStatus(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+		bucketClaim.Status.BucketName = bucketName
+		bucketClaim.Status.BucketReady = bucketReady
+		return nil
+	})
 	if err != nil {
 		klog.V(3).ErrorS(err, "Failed to update status of BucketClaim", "name", bucketClaim.ObjectMeta.Name)
 		return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
 	}
 
-	// Add the finalizers so that bucketClaim is deleted
-	// only after the associated bucket is deleted.
-	controllerutil.AddFinalizer(bucketClaim, util.BucketClaimFinalizer)
-	_, err = b.bucketClaims(bucketClaim.ObjectMeta.Namespace).Update(ctx, bucketClaim, metav1.UpdateOptions{})
+	klog.V(3).Infof("Finished creating Bucket %v", bucketName)
+	return nil
+}
+
+// checkBucketBinding verifies that an existing bucket may be bound to bucketClaim.
+//
+// A bucket that references a bucketClaim UID is bound exclusively to that
+// bucketClaim. A bucket that references a bucketClaim without a UID has been
+// pre-bound by an administrator, and only the named bucketClaim may bind it.
+func checkBucketBinding(bucket *v1alpha1.Bucket, bucketClaim *v1alpha1.BucketClaim) error {
+	ref := bucket.Spec.BucketClaim
+	if ref == nil {
+		return nil
+	}
+
+	if ref.UID != "" {
+		if ref.UID == bucketClaim.ObjectMeta.UID {
+			return nil
+		}
+		return fmt.Errorf("%w: bucket %q is bound to bucket claim %s/%s",
+			util.ErrBucketAlreadyBound, bucket.ObjectMeta.Name, ref.Namespace, ref.Name)
+	}
+
+	if (ref.Namespace != "" && ref.Namespace != bucketClaim.ObjectMeta.Namespace) ||
+		(ref.Name != "" && ref.Name != bucketClaim.ObjectMeta.Name) {
+		return fmt.Errorf("%w: bucket %q is reserved for bucket claim %s/%s",
+			util.ErrBucketAlreadyBound, bucket.ObjectMeta.Name, ref.Namespace, ref.Name)
+	}
+	return nil
+}
+
+// deleteBucketClaimOperation cleans up the bucket of a bucketClaim that is being
+// deleted and removes the bucketClaim finalizer once that is done.
+//
+// Return values
+//   - nil - BucketClaim finalizer removed, or was never added
+//   - ErrWaitingForBucketDeletion - Bucket is still being deleted  [requeue'd with exponential backoff]
+//   - non-nil err - Internal error                                [requeue'd with exponential backoff]
+func (b *BucketClaimListener) deleteBucketClaimOperation(ctx context.Context, inputBucketClaim *v1alpha1.BucketClaim) error {
+	bucketClaim := inputBucketClaim.DeepCopy()
+	if !controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer) {
+		return nil
+	}
+
+	// A bucket may have been created under the reserved name by a
+	// provisioning attempt that did not get to update the status.
+	bucketName := bucketClaim.Status.BucketName
+	if bucketName == "" {
+		bucketName = bucketClaim.ObjectMeta.Annotations[util.BucketNameAnnotation]
+	}
+	if bucketName == "" {
+		buckets, err := b.bucketsForClaim(bucketClaim)
+		if err != nil {
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedDeleteBucket, err)
+		}
+		if len(buckets) == 1 {
+			bucketName = buckets[0].ObjectMeta.Name
+		}
+	}
+	if bucketName != "" {
+		bucket, err := b.getBucket(ctx, bucketName)
+		if err != nil && !kubeerrors.IsNotFound(err) {
+			klog.V(3).ErrorS(err, "Error getting bucket",
+				"bucket", bucketName,
+				"bucketClaim", bucketClaim.ObjectMeta.Name)
+			return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedDeleteBucket, err)
+		}
+		if err != nil || bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != bucketClaim.ObjectMeta.UID {
+			// Already gone, or not bound to this bucketClaim, e.g. the
+			// reserved name was taken by the bucket of another bucketClaim.
+			// Clients return an empty bucket along with errors.
+			bucket = nil
+		}
+
+		if bucket != nil && bucket.Spec.DeletionPolicy == v1alpha1.DeletionPolicyRetain {
+			err = b.releaseBucket(ctx, bucketClaim, bucket)
+			if err != nil {
+				klog.V(3).ErrorS(err, "Error releasing bucket",
+					"bucket", bucketName,
+					"bucketClaim", bucketClaim.ObjectMeta.Name)
+				return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedDeleteBucket, err)
+			}
+		} else if bucket != nil {
+			if bucket.GetDeletionTimestamp().IsZero() {
+				err = b.buckets().Delete(ctx, bucketName, metav1.DeleteOptions{})
+				if err != nil && !kubeerrors.IsNotFound(err) {
+					klog.V(3).ErrorS(err, "Error deleting bucket",
+						"bucket", bucketName,
+						"bucketClaim", bucketClaim.ObjectMeta.Name)
+					return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedDeleteBucket, err)
+				}
+
+				klog.V(5).Infof("Successfully requested deletion of bucket: %s from bucketClaim: %s", bucketName, bucketClaim.ObjectMeta.Name)
+			}
+
+			// The bucket is only gone once the driver sidecar has removed
+			// its own finalizer, check again on the next attempt.
+			b.recordEvent(inputBucketClaim, v1.EventTypeNormal, events.WaitingForBucket,
+				"Waiting for bucket %s to be deleted", bucketName)
+			return util.ErrWaitingForBucketDeletion
+		}
+	}
+
+	_, err := b.patchBucketClaim(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+		controllerutil.RemoveFinalizer(bucketClaim, util.BucketClaimFinalizer)
+		return nil
+	})
+	if err != nil && !kubeerrors.IsNotFound(err) {
+		klog.V(3).ErrorS(err, "Failed to remove finalizer BucketClaim", "name", bucketClaim.ObjectMeta.Name)
+		return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedDeleteBucket, err)
+	}
+
+	klog.V(3).Infof("Finished deleting BucketClaim %v", bucketClaim.ObjectMeta.Name)
+	return nil
+}
+
+// releaseBucket unbinds a Retain bucket from the bucketClaim being deleted,
+// leaving the bucket and its backing storage in place.
+func (b *BucketClaimListener) releaseBucket(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, bucket *v1alpha1.Bucket) error {
+	if bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != bucketClaim.ObjectMeta.UID {
+		// Already released, or bound to another bucketClaim since.
+		return nil
+	}
+
+	err := b.unbindBucket(ctx, bucket)
 	if err != nil {
-		klog.V(3).ErrorS(err, "Failed to add finalizer BucketClaim", "name", bucketClaim.ObjectMeta.Name)
-		return b.recordError(inputBucketClaim, v1.EventTypeWarning, events.FailedCreateBucket, err)
+		return err
 	}
 
-	klog.V(3).Infof("Finished creating Bucket %v", bucketName)
+	klog.V(5).Infof("Successfully released bucket: %s from bucketClaim: %s", bucket.ObjectMeta.Name, bucketClaim.ObjectMeta.Name)
+	b.recordEvent(bucketClaim, v1.EventTypeNormal, util.BucketReleased,
+		"Bucket %s has deletion policy %s and was released", bucket.ObjectMeta.Name, bucket.Spec.DeletionPolicy)
 	return nil
 }
 
+// unbindBucket clears the bucketClaim reference of bucket and marks it as
+// released, so that it can be bound to a bucketClaim again
+func (b *BucketClaimListener) unbindBucket(ctx context.Context, bucket *v1alpha1.Bucket) error {
+	uid := bucket.Spec.BucketClaim.UID
+	_, err := b.patchBucket(ctx, bucket, func(bucket *v1alpha1.Bucket) error {
+		if bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != uid {
+			// Bound to another bucketClaim since.
+			return nil
+		}
+		bucket.Spec.BucketClaim = nil
+		if bucket.ObjectMeta.Annotations == nil {
+			bucket.ObjectMeta.Annotations = map[string]string{}
+		}
+		bucket.ObjectMeta.Annotations[util.BucketReleasedAnnotation] = "true"
+		return nil
+	})
+	return err
+}
+
+// protectBucketClaim adds the finalizer to bucketClaim and reserves
+// bucketName, if set, in the BucketNameAnnotation
+func (b *BucketClaimListener) protectBucketClaim(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, bucketName string) (*v1alpha1.BucketClaim, error) {
+	if controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer) &&
+		(bucketName == "" || bucketClaim.ObjectMeta.Annotations[util.BucketNameAnnotation] == bucketName) {
+		return bucketClaim, nil
+	}
+
+	return b.patchBucketClaim(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+		controllerutil.AddFinalizer(bucketClaim, util.BucketClaimFinalizer)
+		if bucketName != "" {
+			if bucketClaim.ObjectMeta.Annotations == nil {
+				bucketClaim.ObjectMeta.Annotations = map[string]string{}
+			}
+			bucketClaim.ObjectMeta.Annotations[util.BucketNameAnnotation] = bucketName
+		}
+		return nil
+	})
+}
+
+// SetBucketNamer replaces the BucketNamer of a running listener
+func (b *BucketClaimListener) SetBucketNamer(namer BucketNamer) {
+	b.settingsLock.Lock()
+	defer b.settingsLock.Unlock()
+
+	b.bucketNamer = namer
+}
+
+// SetDefaultBucketClassName replaces the default bucketClass of a running listener
+func (b *BucketClaimListener) SetDefaultBucketClassName(name string) {
+	b.settingsLock.Lock()
+	defer b.settingsLock.Unlock()
+
+	b.defaultBucketClassName = name
+}
+
 // InitializeKubeClient initializes the kubernetes client
 func (b *BucketClaimListener) InitializeKubeClient(k kubeclientset.Interface) {
 	b.kubeClient = k
@@ -256,6 +606,30 @@ func (b *BucketClaimListener) bucketClaims(namespace string) objectstoragev1alph
 	panic("uninitialized listener")
 }
 
+func (b *BucketClaimListener) bucketAccesses(namespace string) objectstoragev1alpha1.BucketAccessInterface {
+	if b.bucketClient != nil {
+		return b.bucketClient.ObjectstorageV1alpha1().BucketAccesses(namespace)
+	}
+	panic("uninitialized listener")
+}
+
+// bucketClaimKey returns the key a bucketClaim is tracked under in metrics
+func bucketClaimKey(bucketClaim *v1alpha1.BucketClaim) string {
+	return bucketClaim.ObjectMeta.Namespace + "/" + bucketClaim.ObjectMeta.Name
+}
+
+// bucketClaimState returns the metrics state of a bucketClaim
+func bucketClaimState(bucketClaim *v1alpha1.BucketClaim) string {
+	switch {
+	case !bucketClaim.GetDeletionTimestamp().IsZero():
+		return metrics.BucketClaimTerminating
+	case bucketClaim.Status.BucketReady:
+		return metrics.BucketClaimReady
+	default:
+		return metrics.BucketClaimPending
+	}
+}
+
 // recordError during the processing of the objects
 func (b *BucketClaimListener) recordError(subject runtime.Object, eventtype, reason string, err error) error {
 	if b.eventRecorder == nil {
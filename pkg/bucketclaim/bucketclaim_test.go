@@ -2,19 +2,25 @@ package bucketclaim
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
 	types "sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
 	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
 	"sigs.k8s.io/container-object-storage-interface-api/controller/events"
 	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 var classGoldParameters = map[string]string{
@@ -82,6 +88,40 @@ func TestAddBRIdempotency(t *testing.T) {
 	runCreateBucketIdempotency(t)
 }
 
+// Test that parameters reserved for the controller are not passed on to drivers
+func TestReservedParametersNotCopied(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bucketClass := goldClass.DeepCopy()
+	bucketClass.Parameters = map[string]string{
+		"param1":                           "value1",
+		util.BucketNamingStrategyParameter: NamingStrategyHashed,
+		util.ProtocolsParameter:            "S3,Azure",
+	}
+	client := fakebucketclientset.NewSimpleClientset(bucketClass)
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	if err := listener.Add(ctx, bucketClaim); err != nil {
+		t.Fatalf("Error occurred when provisioning BucketClaim: %v", err)
+	}
+
+	bucketList := util.GetBuckets(ctx, client, 1)
+	if len(bucketList.Items) != 1 {
+		t.Fatalf("Expecting a single Bucket created but found %v", len(bucketList.Items))
+	}
+	expected := map[string]string{"param1": "value1"}
+	if parameters := bucketList.Items[0].Spec.Parameters; !reflect.DeepEqual(parameters, expected) {
+		t.Errorf("expected Bucket parameters %v got %v", expected, parameters)
+	}
+}
+
 func runCreateBucket(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -236,6 +276,391 @@ func runCreateBucketIdempotency(t *testing.T) {
 	}
 }
 
+// Test deleting a bucketClaim whose bucket has a Retain deletion policy
+func TestDeleteBRRetain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	kubeClient := fakekubeclientset.NewSimpleClientset()
+	eventRecorder := record.NewFakeRecorder(3)
+
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(kubeClient)
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	retainClass := goldClass.DeepCopy()
+	retainClass.DeletionPolicy = types.DeletionPolicyRetain
+	bucketclass, err := util.CreateBucketClass(ctx, client, retainClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+
+	listener.Add(ctx, bucketClaim)
+
+	bucketList := util.GetBuckets(ctx, client, 1)
+	defer util.DeleteObjects(ctx, client, *bucketClaim, *bucketclass, bucketList.Items)
+	if len(bucketList.Items) != 1 {
+		t.Fatalf("Expecting a single Bucket created but found %v", len(bucketList.Items))
+	}
+
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+
+	deletedBucketClaim := bucketClaim.DeepCopy()
+	now := metav1.Now()
+	deletedBucketClaim.ObjectMeta.DeletionTimestamp = &now
+
+	err = listener.Update(ctx, bucketClaim, deletedBucketClaim)
+	if err != nil {
+		t.Fatalf("Error occurred when deleting BucketClaim: %v", err)
+	}
+
+	bucket, err := client.ObjectstorageV1alpha1().Buckets().Get(ctx, bucketList.Items[0].Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expecting retained Bucket to exist: %v", err)
+	}
+	if bucket.Spec.BucketClaim != nil {
+		t.Errorf("Expecting retained Bucket to be unbound but found %v", bucket.Spec.BucketClaim)
+	}
+	if bucket.ObjectMeta.Annotations[util.BucketReleasedAnnotation] != "true" {
+		t.Errorf("Expecting retained Bucket to be annotated as released but found %v", bucket.ObjectMeta.Annotations)
+	}
+
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer) {
+		t.Errorf("Expecting finalizer to be removed from BucketClaim but found %v", bucketClaim.Finalizers)
+	}
+
+	expectedEvent := newEvent(
+		v1.EventTypeNormal,
+		util.BucketReleased,
+		fmt.Sprintf("Bucket %s has deletion policy Retain and was released", bucket.Name))
+	select {
+	case event := <-eventRecorder.Events:
+		if event != expectedEvent {
+			t.Errorf("expected %s got %s", expectedEvent, event)
+		}
+	default:
+		t.Errorf("no event after release")
+	}
+}
+
+// Test deleting a bucketClaim whose bucket has a Delete deletion policy
+func TestDeleteBR(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	kubeClient := fakekubeclientset.NewSimpleClientset()
+	eventRecorder := record.NewFakeRecorder(3)
+
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(kubeClient)
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	bucketclass, err := util.CreateBucketClass(ctx, client, &goldClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	defer util.DeleteObjects(ctx, client, *bucketClaim, *bucketclass)
+
+	listener.Add(ctx, bucketClaim)
+
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+
+	deletedBucketClaim := bucketClaim.DeepCopy()
+	now := metav1.Now()
+	deletedBucketClaim.ObjectMeta.DeletionTimestamp = &now
+
+	// The first pass requests deletion of the bucket and waits for it to go away
+	err = listener.Update(ctx, bucketClaim, deletedBucketClaim)
+	if err != util.ErrWaitingForBucketDeletion {
+		t.Fatalf("Expecting %v but got %v", util.ErrWaitingForBucketDeletion, err)
+	}
+
+	bucketList, err := client.ObjectstorageV1alpha1().Buckets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when listing Buckets: %v", err)
+	}
+	if len(bucketList.Items) != 0 {
+		t.Fatalf("Expecting Bucket to be deleted but found %v", len(bucketList.Items))
+	}
+
+	expectedEvent := newEvent(
+		v1.EventTypeNormal,
+		events.WaitingForBucket,
+		fmt.Sprintf("Waiting for bucket %s to be deleted", bucketClaim.Status.BucketName))
+	select {
+	case event := <-eventRecorder.Events:
+		if event != expectedEvent {
+			t.Errorf("expected %s got %s", expectedEvent, event)
+		}
+	default:
+		t.Errorf("no event after bucket deletion")
+	}
+
+	// The second pass finds the bucket gone and releases the bucketClaim
+	err = listener.Update(ctx, bucketClaim, deletedBucketClaim)
+	if err != nil {
+		t.Fatalf("Error occurred when deleting BucketClaim: %v", err)
+	}
+
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer) {
+		t.Errorf("Expecting finalizer to be removed from BucketClaim but found %v", bucketClaim.Finalizers)
+	}
+}
+
+// Test releasing a bucketClaim whose bucket is already gone, with a client
+// that returns an empty bucket along with NotFound like the real one does
+func TestDeleteBRBucketGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bucketClaim := bucketClaim1.DeepCopy()
+	bucketClaim.Finalizers = []string{util.BucketClaimFinalizer}
+	bucketClaim.Status.BucketName = "bucket1"
+	client := fakebucketclientset.NewSimpleClientset(bucketClaim)
+	client.PrependReactor("get", "buckets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		return true, &types.Bucket{}, kubeerrors.NewNotFound(types.Resource("buckets"), name)
+	})
+
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+
+	deletedBucketClaim := bucketClaim.DeepCopy()
+	now := metav1.Now()
+	deletedBucketClaim.ObjectMeta.DeletionTimestamp = &now
+	if err := listener.Update(ctx, bucketClaim, deletedBucketClaim); err != nil {
+		t.Fatalf("Error occurred when deleting BucketClaim: %v", err)
+	}
+
+	released, err := client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if controllerutil.ContainsFinalizer(released, util.BucketClaimFinalizer) {
+		t.Errorf("Expecting finalizer to be removed from BucketClaim but found %v", released.Finalizers)
+	}
+}
+
+// Test that provisioning interrupted at any step is resumed, and that the
+// bucket of an interrupted provisioning is cleaned up on deletion
+func TestProvisionCrashSafe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+
+	bucketclass, err := util.CreateBucketClass(ctx, client, &goldClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	defer util.DeleteObjects(ctx, client, *bucketClaim, *bucketclass)
+
+	failCreate, failStatus, conflictStatus := true, true, true
+	client.PrependReactor("create", "buckets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failCreate {
+			failCreate = false
+			return true, nil, errors.New("connection reset")
+		}
+		return false, nil, nil
+	})
+	client.PrependReactor("patch", "bucketclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" {
+			return false, nil, nil
+		}
+		if failStatus {
+			return true, nil, errors.New("connection reset")
+		}
+		if conflictStatus {
+			conflictStatus = false
+			return true, nil, kubeerrors.NewConflict(v1alpha1.Resource("bucketclaims"), bucketClaim.Name, errors.New("modified"))
+		}
+		return false, nil, nil
+	})
+
+	getBucketClaim := func() *v1alpha1.BucketClaim {
+		bucketClaim, err := client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+		}
+		return bucketClaim
+	}
+
+	// The bucket creation fails after the bucketClaim was protected
+	if err := listener.Add(ctx, bucketClaim); err == nil {
+		t.Fatalf("Expecting bucket creation to fail")
+	}
+	bucketClaim = getBucketClaim()
+	reservedName := bucketClaim.Annotations[util.BucketNameAnnotation]
+	if !controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer) || reservedName == "" {
+		t.Fatalf("Expecting finalizer and reserved bucket name before bucket creation, found %v %v",
+			bucketClaim.Finalizers, bucketClaim.Annotations)
+	}
+
+	// The status update fails after the bucket was created
+	if err := listener.Add(ctx, bucketClaim); err == nil {
+		t.Fatalf("Expecting status update to fail")
+	}
+	if _, err := client.ObjectstorageV1alpha1().Buckets().Get(ctx, reservedName, metav1.GetOptions{}); err != nil {
+		t.Fatalf("Expecting Bucket %s to be created: %v", reservedName, err)
+	}
+
+	// The status update is retried on conflicts
+	failStatus = false
+	if err := listener.Add(ctx, getBucketClaim()); err != nil {
+		t.Fatalf("Error occurred when resuming provisioning: %v", err)
+	}
+	if bucketClaim = getBucketClaim(); bucketClaim.Status.BucketName != reservedName {
+		t.Errorf("Expecting BucketClaim bound to %s but found %q", reservedName, bucketClaim.Status.BucketName)
+	}
+
+	// The bucket is found by its reserved name when the status is missing
+	bucketClaim.Status.BucketName = ""
+	now := metav1.Now()
+	bucketClaim.ObjectMeta.DeletionTimestamp = &now
+	if err := listener.Add(ctx, bucketClaim); err != util.ErrWaitingForBucketDeletion {
+		t.Fatalf("Expecting %v but got %v", util.ErrWaitingForBucketDeletion, err)
+	}
+	if _, err := client.ObjectstorageV1alpha1().Buckets().Get(ctx, reservedName, metav1.GetOptions{}); !kubeerrors.IsNotFound(err) {
+		t.Errorf("Expecting Bucket %s to be deleted got %v", reservedName, err)
+	}
+}
+
+// Test pushing protocol edits of a bound bucketClaim to its bucket
+func TestUpdateBucketProtocols(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	restrictedClass := goldClass.DeepCopy()
+	restrictedClass.Parameters = map[string]string{
+		util.ProtocolsParameter: "S3,Azure",
+	}
+
+	client := fakebucketclientset.NewSimpleClientset(restrictedClass)
+	eventRecorder := record.NewFakeRecorder(10)
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	if err := listener.Add(ctx, bucketClaim); err != nil {
+		t.Fatalf("Error occurred when provisioning BucketClaim: %v", err)
+	}
+	bucketClaim, err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	for len(eventRecorder.Events) > 0 {
+		<-eventRecorder.Events
+	}
+
+	_, err = client.ObjectstorageV1alpha1().BucketAccesses(bucketClaim.Namespace).Create(ctx, &v1alpha1.BucketAccess{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucketaccess1", Namespace: bucketClaim.Namespace},
+		Spec: v1alpha1.BucketAccessSpec{
+			BucketClaimName: bucketClaim.Name,
+			Protocol:        v1alpha1.ProtocolAzure,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketAccess: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name              string
+		protocols         []v1alpha1.Protocol
+		expectedEvent     string
+		expectedProtocols []v1alpha1.Protocol
+	}{
+		{
+			name:              "RemoveUnused",
+			protocols:         []v1alpha1.Protocol{v1alpha1.ProtocolAzure},
+			expectedEvent:     util.BucketProtocolsUpdated,
+			expectedProtocols: []v1alpha1.Protocol{v1alpha1.ProtocolAzure},
+		},
+		{
+			name:              "AddAllowed",
+			protocols:         []v1alpha1.Protocol{v1alpha1.ProtocolAzure, v1alpha1.ProtocolS3},
+			expectedEvent:     util.BucketProtocolsUpdated,
+			expectedProtocols: []v1alpha1.Protocol{v1alpha1.ProtocolAzure, v1alpha1.ProtocolS3},
+		},
+		{
+			name:              "AddNotAllowedByClass",
+			protocols:         []v1alpha1.Protocol{v1alpha1.ProtocolAzure, v1alpha1.ProtocolS3, v1alpha1.ProtocolGCP},
+			expectedEvent:     util.ErrProtocolMismatch.Error(),
+			expectedProtocols: []v1alpha1.Protocol{v1alpha1.ProtocolAzure, v1alpha1.ProtocolS3},
+		},
+		{
+			name:              "RemoveUsedByBucketAccess",
+			protocols:         []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+			expectedEvent:     util.ErrProtocolInUse.Error(),
+			expectedProtocols: []v1alpha1.Protocol{v1alpha1.ProtocolAzure, v1alpha1.ProtocolS3},
+		},
+	} {
+		updated := bucketClaim.DeepCopy()
+		updated.Spec.Protocols = tc.protocols
+		if err := listener.Update(ctx, bucketClaim, updated); err != nil {
+			t.Fatalf("%s: error occurred when updating BucketClaim: %v", tc.name, err)
+		}
+		bucketClaim = updated
+
+		select {
+		case event := <-eventRecorder.Events:
+			if !strings.Contains(event, tc.expectedEvent) {
+				t.Errorf("%s: expected event containing %q got %q", tc.name, tc.expectedEvent, event)
+			}
+		default:
+			t.Errorf("%s: expected event containing %q", tc.name, tc.expectedEvent)
+		}
+
+		bucket, err := client.ObjectstorageV1alpha1().Buckets().Get(ctx, bucketClaim.Status.BucketName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: error occurred when reading Bucket: %v", tc.name, err)
+		}
+		if protocolsChanged(bucket.Spec.Protocols, tc.expectedProtocols) {
+			t.Errorf("%s: expected Bucket protocols %v got %v", tc.name, tc.expectedProtocols, bucket.Spec.Protocols)
+		}
+	}
+}
+
 // Test recording events
 func TestRecordEvents(t *testing.T) {
 	t.Parallel()
@@ -247,6 +672,7 @@ func TestRecordEvents(t *testing.T) {
 		},
 		Spec: v1alpha1.BucketClaimSpec{
 			BucketClassName: "test-bucketClass",
+			Protocols:       []v1alpha1.Protocol{v1alpha1.ProtocolS3},
 		},
 	}
 
@@ -273,6 +699,117 @@ func TestRecordEvents(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "ExistingBucketBoundToOtherClaim",
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				events.FailedCreateBucket,
+				fmt.Sprintf("%s: bucket \"existing-bucket\" is bound to bucket claim other-ns/other-bucketClaim", util.ErrBucketAlreadyBound)),
+			eventTrigger: func(t *testing.T, bcl *BucketClaimListener) {
+				ctx := context.TODO()
+
+				createExistingBucket(t, bcl, &v1.ObjectReference{
+					Name:      "other-bucketClaim",
+					Namespace: "other-ns",
+					UID:       "other-uid",
+				})
+
+				bucketClaim := defaultBucketClaim.DeepCopy()
+				bucketClaim.Spec.ExistingBucketName = "existing-bucket"
+
+				err := bcl.Add(ctx, bucketClaim)
+				if !errors.Is(err, util.ErrBucketAlreadyBound) {
+					t.Errorf("expected %v got %v", util.ErrBucketAlreadyBound, err)
+				}
+			},
+		},
+		{
+			name: "ExistingBucketPreBoundToOtherClaim",
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				events.FailedCreateBucket,
+				fmt.Sprintf("%s: bucket \"existing-bucket\" is reserved for bucket claim test-ns/other-bucketClaim", util.ErrBucketAlreadyBound)),
+			eventTrigger: func(t *testing.T, bcl *BucketClaimListener) {
+				ctx := context.TODO()
+
+				createExistingBucket(t, bcl, &v1.ObjectReference{
+					Name:      "other-bucketClaim",
+					Namespace: "test-ns",
+				})
+
+				bucketClaim := defaultBucketClaim.DeepCopy()
+				bucketClaim.Spec.ExistingBucketName = "existing-bucket"
+
+				err := bcl.Add(ctx, bucketClaim)
+				if !errors.Is(err, util.ErrBucketAlreadyBound) {
+					t.Errorf("expected %v got %v", util.ErrBucketAlreadyBound, err)
+				}
+			},
+		},
+		{
+			name: "NoProtocols",
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				events.FailedCreateBucket,
+				util.ErrNoProtocols.Error()),
+			eventTrigger: func(t *testing.T, bcl *BucketClaimListener) {
+				ctx := context.TODO()
+
+				bucketClaim := defaultBucketClaim.DeepCopy()
+				bucketClaim.Spec.Protocols = nil
+
+				err := bcl.Add(ctx, bucketClaim)
+				if !errors.Is(err, util.ErrNoProtocols) {
+					t.Errorf("expected %v got %v", util.ErrNoProtocols, err)
+				}
+			},
+		},
+		{
+			name: "BucketClassProtocolMismatch",
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				util.ProtocolMismatch,
+				fmt.Sprintf("%s: \"S3\" is not allowed by bucket class \"test-bucketClass\", allowed protocols are [Azure GCP]", util.ErrProtocolMismatch)),
+			eventTrigger: func(t *testing.T, bcl *BucketClaimListener) {
+				ctx := context.TODO()
+
+				bucketClass := goldClass.DeepCopy()
+				bucketClass.Name = "test-bucketClass"
+				bucketClass.Parameters = map[string]string{util.ProtocolsParameter: "Azure, GCP"}
+				if _, err := util.CreateBucketClass(ctx, bcl.bucketClient, bucketClass); err != nil {
+					t.Fatalf("Error occurred when creating BucketClass: %v", err)
+				}
+
+				err := bcl.Add(ctx, defaultBucketClaim.DeepCopy())
+				if !errors.Is(err, util.ErrProtocolMismatch) {
+					t.Errorf("expected %v got %v", util.ErrProtocolMismatch, err)
+				}
+			},
+		},
+		{
+			name: "ExistingBucketProtocolMismatch",
+			expectedEvent: newEvent(
+				v1.EventTypeWarning,
+				util.ProtocolMismatch,
+				fmt.Sprintf("%s: bucket \"existing-bucket\" supports [Azure] but bucket claim requests [S3]", util.ErrProtocolMismatch)),
+			eventTrigger: func(t *testing.T, bcl *BucketClaimListener) {
+				ctx := context.TODO()
+
+				bucket := createExistingBucket(t, bcl, nil)
+				bucket.Spec.Protocols = []v1alpha1.Protocol{v1alpha1.ProtocolAzure}
+				if _, err := bcl.buckets().Update(ctx, bucket, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("Error occurred when updating Bucket: %v", err)
+				}
+
+				bucketClaim := defaultBucketClaim.DeepCopy()
+				bucketClaim.Spec.ExistingBucketName = "existing-bucket"
+
+				err := bcl.Add(ctx, bucketClaim)
+				if !errors.Is(err, util.ErrProtocolMismatch) {
+					t.Errorf("expected %v got %v", util.ErrProtocolMismatch, err)
+				}
+			},
+		},
 		{
 			name: "BucketClassNotFound",
 			expectedEvent: newEvent(
@@ -322,6 +859,24 @@ func TestRecordEvents(t *testing.T) {
 	}
 }
 
+func createExistingBucket(t *testing.T, bcl *BucketClaimListener, bucketClaimRef *v1.ObjectReference) *types.Bucket {
+	bucket := &types.Bucket{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "existing-bucket",
+		},
+		Spec: types.BucketSpec{
+			DriverName:     "sample.cosi.driver",
+			BucketClaim:    bucketClaimRef,
+			DeletionPolicy: types.DeletionPolicyRetain,
+		},
+	}
+	bucket, err := bcl.buckets().Create(context.TODO(), bucket, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when creating Bucket: %v", err)
+	}
+	return bucket
+}
+
 func newEvent(eventType, reason, message string) string {
 	return fmt.Sprintf("%s %s %s", eventType, reason, message)
 }
@@ -0,0 +1,243 @@
+package bucketclaim
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	bucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// BucketClassListener is a resource handler for bucket class objects. It
+// requeues the bucketClaims waiting for a bucketClass once it is created or
+// updated, instead of leaving them to their exponential backoff, and protects
+// bucketClasses that are in use from deletion.
+type BucketClassListener struct {
+	claims *BucketClaimListener
+}
+
+// NewBucketClassListener returns a BucketClassListener that requeues
+// bucketClaims through the given BucketClaimListener
+func NewBucketClassListener(claims *BucketClaimListener) *BucketClassListener {
+	return &BucketClassListener{
+		claims: claims,
+	}
+}
+
+// Add protects a new bucketClass and requeues the bucketClaims waiting for it
+func (b *BucketClassListener) Add(ctx context.Context, bucketClass *v1alpha1.BucketClass) (err error) {
+	klog.V(3).InfoS("Add BucketClass",
+		"name", bucketClass.ObjectMeta.Name)
+	done := metrics.StartReconcile("BucketClass", "Add")
+	defer func() { done(err) }()
+
+	err = b.claims.syncBucketClassProtection(ctx, bucketClass.ObjectMeta.Name)
+	if err != nil {
+		return err
+	}
+	return b.claims.requeueWaitingBucketClaims(ctx, bucketClass)
+}
+
+// Update protects an updated bucketClass and requeues the bucketClaims
+// waiting for it if the update can change their provisioning
+func (b *BucketClassListener) Update(ctx context.Context, old, new *v1alpha1.BucketClass) (err error) {
+	klog.V(3).InfoS("Update BucketClass",
+		"name", old.Name)
+	done := metrics.StartReconcile("BucketClass", "Update")
+	defer func() { done(err) }()
+
+	err = b.claims.syncBucketClassProtection(ctx, new.ObjectMeta.Name)
+	if err != nil || !new.GetDeletionTimestamp().IsZero() || !bucketClassChanged(old, new) {
+		return err
+	}
+	return b.claims.requeueWaitingBucketClaims(ctx, new)
+}
+
+// Delete processes a deleted bucketClass
+func (b *BucketClassListener) Delete(ctx context.Context, bucketClass *v1alpha1.BucketClass) error {
+	klog.V(3).InfoS("Delete BucketClass",
+		"name", bucketClass.ObjectMeta.Name)
+	done := metrics.StartReconcile("BucketClass", "Delete")
+	defer done(nil)
+
+	return nil
+}
+
+// InitializeKubeClient initializes the kubernetes client
+func (b *BucketClassListener) InitializeKubeClient(k kubeclientset.Interface) {
+	b.claims.InitializeKubeClient(k)
+}
+
+// InitializeBucketClient initializes the object storage bucket client
+func (b *BucketClassListener) InitializeBucketClient(bc bucketclientset.Interface) {
+	b.claims.InitializeBucketClient(bc)
+}
+
+// InitializeEventRecorder initializes the event recorder
+func (b *BucketClassListener) InitializeEventRecorder(er record.EventRecorder) {
+	b.claims.InitializeEventRecorder(er)
+}
+
+// bucketClassChanged reports whether an update of a bucketClass can change the
+// provisioning of the bucketClaims waiting for it. Other updates, such as the
+// protection finalizer written by the controller itself, do not requeue them.
+func bucketClassChanged(old, new *v1alpha1.BucketClass) bool {
+	return old.DriverName != new.DriverName ||
+		old.DeletionPolicy != new.DeletionPolicy ||
+		!equality.Semantic.DeepEqual(old.Parameters, new.Parameters) ||
+		isDefaultBucketClass(old) != isDefaultBucketClass(new)
+}
+
+// requeueWaitingBucketClaims annotates the bucketClaims that request
+// bucketClass and have no bucket yet, so that their update is processed right
+// away. BucketClaims without a bucketClass are requeued as well when
+// bucketClass is the cluster default.
+func (b *BucketClaimListener) requeueWaitingBucketClaims(ctx context.Context, bucketClass *v1alpha1.BucketClass) error {
+	bucketClaims, err := b.bucketClaimsForClass(ctx, bucketClass.ObjectMeta.Name)
+	if err != nil {
+		return err
+	}
+	if isDefaultBucketClass(bucketClass) {
+		defaulted, err := b.bucketClaimsForClass(ctx, "")
+		if err != nil {
+			return err
+		}
+		bucketClaims = append(bucketClaims, defaulted...)
+	}
+
+	requeuedBy := fmt.Sprintf("%s/%s", bucketClass.ObjectMeta.Name, bucketClass.ObjectMeta.ResourceVersion)
+	for _, bucketClaim := range bucketClaims {
+		if bucketClaim.Status.BucketName != "" || !bucketClaim.GetDeletionTimestamp().IsZero() ||
+			bucketClaim.ObjectMeta.Annotations[util.RequeuedByBucketClassAnnotation] == requeuedBy {
+			continue
+		}
+
+		_, err := b.patchBucketClaim(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+			if bucketClaim.ObjectMeta.Annotations == nil {
+				bucketClaim.ObjectMeta.Annotations = map[string]string{}
+			}
+			bucketClaim.ObjectMeta.Annotations[util.RequeuedByBucketClassAnnotation] = requeuedBy
+			return nil
+		})
+		if err != nil && !kubeerrors.IsNotFound(err) {
+			klog.V(3).ErrorS(err, "Failed to requeue BucketClaim",
+				"name", bucketClaim.ObjectMeta.Name,
+				"ns", bucketClaim.ObjectMeta.Namespace,
+				"bucketClass", bucketClass.ObjectMeta.Name)
+			return err
+		}
+
+		klog.V(5).InfoS("Requeued BucketClaim waiting for BucketClass",
+			"name", bucketClaim.ObjectMeta.Name,
+			"ns", bucketClaim.ObjectMeta.Namespace,
+			"bucketClass", bucketClass.ObjectMeta.Name)
+	}
+	return nil
+}
+
+// protectBucketClass adds the protection finalizer to bucketClass, unless it
+// has it already or is being deleted
+func (b *BucketClaimListener) protectBucketClass(ctx context.Context, bucketClass *v1alpha1.BucketClass) error {
+	if controllerutil.ContainsFinalizer(bucketClass, util.BucketClassFinalizer) || !bucketClass.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+
+	_, err := b.patchBucketClass(ctx, bucketClass, func(bucketClass *v1alpha1.BucketClass) error {
+		if bucketClass.GetDeletionTimestamp().IsZero() {
+			controllerutil.AddFinalizer(bucketClass, util.BucketClassFinalizer)
+		}
+		return nil
+	})
+	return err
+}
+
+// syncBucketClassProtection keeps the protection finalizer on the named
+// bucketClass while bucketClaims or buckets reference it, and removes it once
+// the last reference is gone. A bucketClass whose deletion is blocked gets an
+// event listing the bucketClaims referencing it.
+func (b *BucketClaimListener) syncBucketClassProtection(ctx context.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	bucketClass, err := b.getBucketClass(ctx, name)
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	deleting := !bucketClass.GetDeletionTimestamp().IsZero()
+	protected := controllerutil.ContainsFinalizer(bucketClass, util.BucketClassFinalizer)
+
+	bucketClaims, buckets, err := b.bucketClassReferences(ctx, name, false)
+	if err != nil {
+		return err
+	}
+	// The cache may still hold references that were just deleted, so a
+	// blocked deletion is confirmed against the API server
+	if deleting && protected && len(bucketClaims)+len(buckets) > 0 && b.listers != nil {
+		bucketClaims, buckets, err = b.bucketClassReferences(ctx, name, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(bucketClaims)+len(buckets) == 0:
+		if !protected {
+			return nil
+		}
+		_, err = b.patchBucketClass(ctx, bucketClass, func(bucketClass *v1alpha1.BucketClass) error {
+			controllerutil.RemoveFinalizer(bucketClass, util.BucketClassFinalizer)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		klog.V(3).InfoS("Removed BucketClass protection",
+			"name", name)
+		return nil
+	case deleting:
+		keys := make([]string, 0, len(bucketClaims))
+		for _, bucketClaim := range bucketClaims {
+			keys = append(keys, bucketClaimKey(bucketClaim))
+		}
+		sort.Strings(keys)
+		b.recordEvent(bucketClass, v1.EventTypeWarning, util.BucketClassInUse,
+			"BucketClass is in use by %d bucket claims [%s] and %d buckets",
+			len(bucketClaims), util.JoinNames(keys), len(buckets))
+		return nil
+	default:
+		return b.protectBucketClass(ctx, bucketClass)
+	}
+}
+
+// bucketClassReferences returns the bucketClaims and buckets referencing the
+// named bucketClass, from the API server if live is set
+func (b *BucketClaimListener) bucketClassReferences(ctx context.Context, name string, live bool) ([]*v1alpha1.BucketClaim, []*v1alpha1.Bucket, error) {
+	bucketClaimsFor, bucketsFor := b.bucketClaimsForClass, b.bucketsForClass
+	if live {
+		bucketClaimsFor, bucketsFor = b.listBucketClaimsForClass, b.listBucketsForClass
+	}
+
+	bucketClaims, err := bucketClaimsFor(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	buckets, err := bucketsFor(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bucketClaims, buckets, nil
+}
@@ -0,0 +1,278 @@
+package bucketclaim
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	bucketinformers "sigs.k8s.io/container-object-storage-interface-api/client/informers/externalversions"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Test requeueing bucketClaims that wait for a bucketClass once it appears
+func TestBucketClassRequeuesWaitingClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	kubeClient := fakekubeclientset.NewSimpleClientset()
+	eventRecorder := record.NewFakeRecorder(10)
+
+	claimListener := NewBucketClaimListener()
+	listener := NewBucketClassListener(claimListener)
+	listener.InitializeKubeClient(kubeClient)
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	waiting, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	otherClaim := bucketClaim2.DeepCopy()
+	otherClaim.Spec.BucketClassName = "classsilver"
+	other, err := util.CreateBucketClaim(ctx, client, otherClaim)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+
+	// Provisioning fails while the bucketClass is missing
+	if err := claimListener.Add(ctx, waiting); err == nil {
+		t.Fatalf("Expected provisioning to fail without a BucketClass")
+	}
+
+	bucketClass, err := util.CreateBucketClass(ctx, client, &goldClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+	if err := listener.Add(ctx, bucketClass); err != nil {
+		t.Fatalf("Error occurred when adding BucketClass: %v", err)
+	}
+
+	requeued, err := client.ObjectstorageV1alpha1().BucketClaims(waiting.Namespace).Get(ctx, waiting.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if _, ok := requeued.Annotations[util.RequeuedByBucketClassAnnotation]; !ok {
+		t.Errorf("Expected BucketClaim waiting for the BucketClass to be requeued")
+	}
+
+	other, err = client.ObjectstorageV1alpha1().BucketClaims(other.Namespace).Get(ctx, other.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if _, ok := other.Annotations[util.RequeuedByBucketClassAnnotation]; ok {
+		t.Errorf("Expected BucketClaim of another BucketClass not to be requeued")
+	}
+
+	// The update event caused by the annotation provisions the bucketClaim
+	if err := claimListener.Update(ctx, waiting, requeued); err != nil {
+		t.Fatalf("Error occurred when updating BucketClaim: %v", err)
+	}
+
+	bucketList := util.GetBuckets(ctx, client, 1)
+	defer util.DeleteObjects(ctx, client, *requeued, *bucketClass, bucketList.Items)
+	if len(bucketList.Items) != 1 {
+		t.Fatalf("Expecting a single Bucket created but found %v", len(bucketList.Items))
+	}
+}
+
+// Test that only updates changing the provisioning of waiting bucketClaims
+// requeue them
+func TestBucketClassUpdateRequeue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	claimListener := NewBucketClaimListener()
+	listener := NewBucketClassListener(claimListener)
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+
+	waiting, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	bucketClass, err := util.CreateBucketClass(ctx, client, &goldClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+
+	isRequeued := func() bool {
+		t.Helper()
+		bucketClaim, err := client.ObjectstorageV1alpha1().BucketClaims(waiting.Namespace).Get(ctx, waiting.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+		}
+		_, ok := bucketClaim.Annotations[util.RequeuedByBucketClassAnnotation]
+		return ok
+	}
+
+	for _, tc := range []struct {
+		name             string
+		mutate           func(*v1alpha1.BucketClass)
+		expectedRequeued bool
+	}{
+		{
+			name: "Finalizer",
+			mutate: func(bucketClass *v1alpha1.BucketClass) {
+				controllerutil.AddFinalizer(bucketClass, util.BucketClassFinalizer)
+			},
+		},
+		{
+			name: "Label",
+			mutate: func(bucketClass *v1alpha1.BucketClass) {
+				bucketClass.Labels = map[string]string{"team": "storage"}
+			},
+		},
+		{
+			name: "Parameters",
+			mutate: func(bucketClass *v1alpha1.BucketClass) {
+				bucketClass.Parameters = map[string]string{"tier": "cold"}
+			},
+			expectedRequeued: true,
+		},
+	} {
+		updated := bucketClass.DeepCopy()
+		tc.mutate(updated)
+		updated.ResourceVersion = tc.name
+		if err := listener.Update(ctx, bucketClass, updated); err != nil {
+			t.Fatalf("Error occurred when updating BucketClass: %v", err)
+		}
+		if requeued := isRequeued(); requeued != tc.expectedRequeued {
+			t.Errorf("%s: expected requeued %t got %t", tc.name, tc.expectedRequeued, requeued)
+		}
+	}
+}
+
+// Test protecting a bucketClass from deletion while bucketClaims reference it
+func TestBucketClassProtection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset()
+	kubeClient := fakekubeclientset.NewSimpleClientset()
+	eventRecorder := record.NewFakeRecorder(10)
+
+	claimListener := NewBucketClaimListener()
+	listener := NewBucketClassListener(claimListener)
+	listener.InitializeKubeClient(kubeClient)
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	bucketClass, err := util.CreateBucketClass(ctx, client, &goldClass)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClass: %v", err)
+	}
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+
+	if err := listener.Add(ctx, bucketClass); err != nil {
+		t.Fatalf("Error occurred when adding BucketClass: %v", err)
+	}
+	protected, err := client.ObjectstorageV1alpha1().BucketClasses().Get(ctx, bucketClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClass: %v", err)
+	}
+	if !controllerutil.ContainsFinalizer(protected, util.BucketClassFinalizer) {
+		t.Fatalf("Expected BucketClass in use to be protected got finalizers %v", protected.Finalizers)
+	}
+
+	deleting := protected.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	deleting, err = client.ObjectstorageV1alpha1().BucketClasses().Update(ctx, deleting, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when updating BucketClass: %v", err)
+	}
+	if err := listener.Update(ctx, protected, deleting); err != nil {
+		t.Fatalf("Error occurred when updating BucketClass: %v", err)
+	}
+
+	select {
+	case event := <-eventRecorder.Events:
+		if !strings.Contains(event, util.BucketClassInUse) || !strings.Contains(event, "default/bucketclaim1") {
+			t.Errorf("Expected event listing the BucketClaim got %q", event)
+		}
+	default:
+		t.Errorf("Expected an event on the BucketClass whose deletion is blocked")
+	}
+
+	err = client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Delete(ctx, bucketClaim.Name, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when deleting BucketClaim: %v", err)
+	}
+	if err := claimListener.Delete(ctx, bucketClaim); err != nil {
+		t.Fatalf("Error occurred when deleting BucketClaim: %v", err)
+	}
+
+	released, err := client.ObjectstorageV1alpha1().BucketClasses().Get(ctx, bucketClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClass: %v", err)
+	}
+	if controllerutil.ContainsFinalizer(released, util.BucketClassFinalizer) {
+		t.Errorf("Expected BucketClass to be released after its last BucketClaim got finalizers %v", released.Finalizers)
+	}
+}
+
+// Test class protection and requeueing against the informer caches
+func TestBucketClassInformers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bucket := &v1alpha1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket1"},
+		Spec:       v1alpha1.BucketSpec{BucketClassName: goldClass.Name},
+	}
+	client := fakebucketclientset.NewSimpleClientset(goldClass.DeepCopy(), bucketClaim1.DeepCopy(), bucket)
+	factory := bucketinformers.NewSharedInformerFactory(client, 0)
+	informers := factory.Objectstorage().V1alpha1()
+	if err := AddIndexers(informers); err != nil {
+		t.Fatalf("Error occurred when adding indexers: %v", err)
+	}
+
+	claimListener := NewBucketClaimListener(WithInformers(informers))
+	listener := NewBucketClassListener(claimListener)
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(record.NewFakeRecorder(10))
+
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	buckets, err := claimListener.bucketsForClass(ctx, goldClass.Name)
+	if err != nil || len(buckets) != 1 {
+		t.Fatalf("Expected 1 Bucket indexed for BucketClass got %d: %v", len(buckets), err)
+	}
+
+	bucketClass, err := client.ObjectstorageV1alpha1().BucketClasses().Get(ctx, goldClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClass: %v", err)
+	}
+	if err := listener.Add(ctx, bucketClass); err != nil {
+		t.Fatalf("Error occurred when adding BucketClass: %v", err)
+	}
+
+	protected, err := client.ObjectstorageV1alpha1().BucketClasses().Get(ctx, goldClass.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClass: %v", err)
+	}
+	if !controllerutil.ContainsFinalizer(protected, util.BucketClassFinalizer) {
+		t.Errorf("Expected BucketClass in use to be protected got finalizers %v", protected.Finalizers)
+	}
+
+	requeued, err := client.ObjectstorageV1alpha1().BucketClaims(bucketClaim1.Namespace).Get(ctx, bucketClaim1.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if _, ok := requeued.Annotations[util.RequeuedByBucketClassAnnotation]; !ok {
+		t.Errorf("Expected BucketClaim waiting for the BucketClass to be requeued")
+	}
+}
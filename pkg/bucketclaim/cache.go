@@ -0,0 +1,245 @@
+package bucketclaim
+
+import (
+	"context"
+	"fmt"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	objectstorageinformers "sigs.k8s.io/container-object-storage-interface-api/client/informers/externalversions/objectstorage/v1alpha1"
+	bucketlisters "sigs.k8s.io/container-object-storage-interface-api/client/listers/objectstorage/v1alpha1"
+)
+
+// Names of the indexes AddIndexers adds to the shared informers
+const (
+	// BucketClaimsByClassIndex indexes bucketClaims by the name of the
+	// bucketClass they request, bucketClaims without one are indexed by ""
+	BucketClaimsByClassIndex = "bucketClaimsByClass"
+	// BucketsByClaimUIDIndex indexes buckets by the UID of the bucketClaim
+	// they are bound to
+	BucketsByClaimUIDIndex = "bucketsByClaimUID"
+	// BucketsByClassIndex indexes buckets by the name of their bucketClass
+	BucketsByClassIndex = "bucketsByClass"
+)
+
+// listers are the informer caches a BucketClaimListener reads from
+type listers struct {
+	bucketClasses      bucketlisters.BucketClassLister
+	buckets            bucketlisters.BucketLister
+	bucketClaims       bucketlisters.BucketClaimLister
+	bucketIndexer      cache.Indexer
+	bucketClaimIndexer cache.Indexer
+}
+
+// AddIndexers adds the indexes the BucketClaimListener looks objects up by to
+// the shared informers. It must be called before the informers are started.
+func AddIndexers(informers objectstorageinformers.Interface) error {
+	err := informers.BucketClaims().Informer().AddIndexers(cache.Indexers{
+		BucketClaimsByClassIndex: func(obj interface{}) ([]string, error) {
+			bucketClaim, ok := obj.(*v1alpha1.BucketClaim)
+			if !ok {
+				return nil, nil
+			}
+			return []string{bucketClaim.Spec.BucketClassName}, nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return informers.Buckets().Informer().AddIndexers(cache.Indexers{
+		BucketsByClaimUIDIndex: func(obj interface{}) ([]string, error) {
+			bucket, ok := obj.(*v1alpha1.Bucket)
+			if !ok || bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID == "" {
+				return nil, nil
+			}
+			return []string{string(bucket.Spec.BucketClaim.UID)}, nil
+		},
+		BucketsByClassIndex: func(obj interface{}) ([]string, error) {
+			bucket, ok := obj.(*v1alpha1.Bucket)
+			if !ok || bucket.Spec.BucketClassName == "" {
+				return nil, nil
+			}
+			return []string{bucket.Spec.BucketClassName}, nil
+		},
+	})
+}
+
+// WithInformers makes the listener read bucketClasses, buckets and
+// bucketClaims from the caches of the shared informers, which must have the
+// indexes added by AddIndexers
+func WithInformers(informers objectstorageinformers.Interface) Option {
+	return func(b *BucketClaimListener) {
+		b.listers = &listers{
+			bucketClasses:      informers.BucketClasses().Lister(),
+			buckets:            informers.Buckets().Lister(),
+			bucketClaims:       informers.BucketClaims().Lister(),
+			bucketIndexer:      informers.Buckets().Informer().GetIndexer(),
+			bucketClaimIndexer: informers.BucketClaims().Informer().GetIndexer(),
+		}
+	}
+}
+
+// getBucketClass returns the named bucketClass from the cache. Misses are
+// confirmed against the API server, as the cache may lag behind.
+func (b *BucketClaimListener) getBucketClass(ctx context.Context, name string) (*v1alpha1.BucketClass, error) {
+	if b.listers != nil {
+		bucketClass, err := b.listers.bucketClasses.Get(name)
+		if err == nil {
+			return bucketClass.DeepCopy(), nil
+		} else if !kubeerrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return b.bucketClasses().Get(ctx, name, metav1.GetOptions{})
+}
+
+// listBucketClasses returns all bucketClasses from the cache
+func (b *BucketClaimListener) listBucketClasses(ctx context.Context) ([]v1alpha1.BucketClass, error) {
+	if b.listers == nil {
+		bucketClasses, err := b.bucketClasses().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return bucketClasses.Items, nil
+	}
+
+	cached, err := b.listers.bucketClasses.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	bucketClasses := make([]v1alpha1.BucketClass, 0, len(cached))
+	for _, bucketClass := range cached {
+		bucketClasses = append(bucketClasses, *bucketClass.DeepCopy())
+	}
+	return bucketClasses, nil
+}
+
+// getBucket returns the named bucket from the cache. Misses are confirmed
+// against the API server, as the cache may lag behind.
+func (b *BucketClaimListener) getBucket(ctx context.Context, name string) (*v1alpha1.Bucket, error) {
+	if b.listers != nil {
+		bucket, err := b.listers.buckets.Get(name)
+		if err == nil {
+			return bucket.DeepCopy(), nil
+		} else if !kubeerrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return b.buckets().Get(ctx, name, metav1.GetOptions{})
+}
+
+// getBucketClaim returns the named bucketClaim from the cache. Misses are
+// confirmed against the API server, as the cache may lag behind.
+func (b *BucketClaimListener) getBucketClaim(ctx context.Context, namespace, name string) (*v1alpha1.BucketClaim, error) {
+	if b.listers != nil {
+		bucketClaim, err := b.listers.bucketClaims.BucketClaims(namespace).Get(name)
+		if err == nil {
+			return bucketClaim.DeepCopy(), nil
+		} else if !kubeerrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return b.bucketClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// bucketsForClaim returns the cached buckets bound to bucketClaim. Without
+// informers it returns nothing.
+func (b *BucketClaimListener) bucketsForClaim(bucketClaim *v1alpha1.BucketClaim) ([]*v1alpha1.Bucket, error) {
+	if b.listers == nil {
+		return nil, nil
+	}
+
+	objs, err := b.listers.bucketIndexer.ByIndex(BucketsByClaimUIDIndex, string(bucketClaim.ObjectMeta.UID))
+	if err != nil {
+		return nil, err
+	}
+	buckets := make([]*v1alpha1.Bucket, 0, len(objs))
+	for _, obj := range objs {
+		bucket, ok := obj.(*v1alpha1.Bucket)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %T in bucket index", obj)
+		}
+		buckets = append(buckets, bucket.DeepCopy())
+	}
+	return buckets, nil
+}
+
+// bucketClaimsForClass returns the cached bucketClaims that request the named
+// bucketClass. Without informers they are listed from the API server.
+func (b *BucketClaimListener) bucketClaimsForClass(ctx context.Context, bucketClassName string) ([]*v1alpha1.BucketClaim, error) {
+	if b.listers == nil {
+		return b.listBucketClaimsForClass(ctx, bucketClassName)
+	}
+
+	objs, err := b.listers.bucketClaimIndexer.ByIndex(BucketClaimsByClassIndex, bucketClassName)
+	if err != nil {
+		return nil, err
+	}
+	bucketClaims := make([]*v1alpha1.BucketClaim, 0, len(objs))
+	for _, obj := range objs {
+		bucketClaim, ok := obj.(*v1alpha1.BucketClaim)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %T in bucket claim index", obj)
+		}
+		bucketClaims = append(bucketClaims, bucketClaim.DeepCopy())
+	}
+	return bucketClaims, nil
+}
+
+// listBucketClaimsForClass returns the bucketClaims that request the named
+// bucketClass from the API server
+func (b *BucketClaimListener) listBucketClaimsForClass(ctx context.Context, bucketClassName string) ([]*v1alpha1.BucketClaim, error) {
+	list, err := b.bucketClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	bucketClaims := []*v1alpha1.BucketClaim{}
+	for i := range list.Items {
+		if list.Items[i].Spec.BucketClassName == bucketClassName {
+			bucketClaims = append(bucketClaims, &list.Items[i])
+		}
+	}
+	return bucketClaims, nil
+}
+
+// bucketsForClass returns the cached buckets of the named bucketClass.
+// Without informers they are listed from the API server.
+func (b *BucketClaimListener) bucketsForClass(ctx context.Context, bucketClassName string) ([]*v1alpha1.Bucket, error) {
+	if b.listers == nil {
+		return b.listBucketsForClass(ctx, bucketClassName)
+	}
+
+	objs, err := b.listers.bucketIndexer.ByIndex(BucketsByClassIndex, bucketClassName)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make([]*v1alpha1.Bucket, 0, len(objs))
+	for _, obj := range objs {
+		bucket, ok := obj.(*v1alpha1.Bucket)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %T in bucket index", obj)
+		}
+		buckets = append(buckets, bucket.DeepCopy())
+	}
+	return buckets, nil
+}
+
+// listBucketsForClass returns the buckets of the named bucketClass from the
+// API server
+func (b *BucketClaimListener) listBucketsForClass(ctx context.Context, bucketClassName string) ([]*v1alpha1.Bucket, error) {
+	list, err := b.buckets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	buckets := []*v1alpha1.Bucket{}
+	for i := range list.Items {
+		if list.Items[i].Spec.BucketClassName == bucketClassName {
+			buckets = append(buckets, &list.Items[i])
+		}
+	}
+	return buckets, nil
+}
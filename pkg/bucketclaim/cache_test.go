@@ -0,0 +1,55 @@
+package bucketclaim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	bucketinformers "sigs.k8s.io/container-object-storage-interface-api/client/informers/externalversions"
+)
+
+// Test that bucketClasses are read from the informer cache, and that buckets
+// are indexed by the UID of their bucketClaim
+func TestInformerCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset(goldClass.DeepCopy(), bucketClaim1.DeepCopy())
+	factory := bucketinformers.NewSharedInformerFactory(client, 0)
+	informers := factory.Objectstorage().V1alpha1()
+	if err := AddIndexers(informers); err != nil {
+		t.Fatalf("Error occurred when adding indexers: %v", err)
+	}
+
+	listener := NewBucketClaimListener(WithInformers(informers))
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	client.ClearActions()
+
+	bucketClaim := bucketClaim1.DeepCopy()
+	if err := listener.Add(ctx, bucketClaim); err != nil {
+		t.Fatalf("Error occurred when provisioning BucketClaim: %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "get" || action.GetVerb() == "list" {
+			t.Errorf("unexpected live read %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+
+	var buckets int
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		found, err := listener.bucketsForClaim(bucketClaim)
+		buckets = len(found)
+		return buckets == 1, err
+	})
+	if err != nil {
+		t.Errorf("expected 1 Bucket indexed for BucketClaim got %d: %v", buckets, err)
+	}
+}
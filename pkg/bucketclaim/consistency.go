@@ -0,0 +1,215 @@
+package bucketclaim
+
+import (
+	"context"
+	"fmt"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Kinds of inconsistencies found by the ConsistencyChecker
+const (
+	// FindingMissingBucket is a bucketClaim whose status names a bucket
+	// that does not exist
+	FindingMissingBucket = "MissingBucket"
+	// FindingBucketNotBoundToClaim is a bucketClaim whose status names a
+	// bucket that is not bound to it
+	FindingBucketNotBoundToClaim = "BucketNotBoundToClaim"
+	// FindingBucketClaimUIDMismatch is a bucket bound to a bucketClaim that
+	// was deleted and recreated under the same name
+	FindingBucketClaimUIDMismatch = "BucketClaimUIDMismatch"
+	// FindingFinalizerWithoutStatus is a bucketClaim with the finalizer
+	// that has no bucket in its status
+	FindingFinalizerWithoutStatus = "FinalizerWithoutStatus"
+	// FindingStatusWithoutFinalizer is a bucketClaim with a bucket in its
+	// status that is not protected by the finalizer
+	FindingStatusWithoutFinalizer = "StatusWithoutFinalizer"
+)
+
+// Finding describes an inconsistency between bucketClaims and buckets
+type Finding struct {
+	Kind        string `json:"kind"`
+	BucketClaim string `json:"bucketClaim,omitempty"`
+	Bucket      string `json:"bucket,omitempty"`
+	Message     string `json:"message"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repairError,omitempty"`
+}
+
+// ConsistencyChecker finds, and optionally repairs, the states left behind by
+// provisioning or deletion that failed part way through
+type ConsistencyChecker struct {
+	claims *BucketClaimListener
+	repair bool
+}
+
+// NewConsistencyChecker returns a ConsistencyChecker acting through the
+// clients of claims. Without repair it only reports its findings.
+func NewConsistencyChecker(claims *BucketClaimListener, repair bool) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		claims: claims,
+		repair: repair,
+	}
+}
+
+// Check returns the inconsistencies found between all bucketClaims and buckets.
+// BucketClaims that are being deleted are left to the BucketClaimListener.
+func (c *ConsistencyChecker) Check(ctx context.Context) ([]Finding, error) {
+	bucketClaims, err := c.claims.bucketClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := c.claims.buckets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	bucketsByName := map[string]*v1alpha1.Bucket{}
+	for i := range buckets.Items {
+		bucketsByName[buckets.Items[i].ObjectMeta.Name] = &buckets.Items[i]
+	}
+	claimsByKey := map[string]*v1alpha1.BucketClaim{}
+	for i := range bucketClaims.Items {
+		claimsByKey[bucketClaimKey(&bucketClaims.Items[i])] = &bucketClaims.Items[i]
+	}
+
+	findings := []Finding{}
+	for i := range bucketClaims.Items {
+		bucketClaim := &bucketClaims.Items[i]
+		if !bucketClaim.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if finding := c.checkBucketClaim(ctx, bucketClaim, bucketsByName); finding != nil {
+			findings = append(findings, *finding)
+		}
+	}
+
+	for i := range buckets.Items {
+		bucket := &buckets.Items[i]
+		ref := bucket.Spec.BucketClaim
+		if ref == nil || ref.UID == "" || !bucket.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		bucketClaim, ok := claimsByKey[ref.Namespace+"/"+ref.Name]
+		if !ok || bucketClaim.ObjectMeta.UID == ref.UID {
+			continue
+		}
+		finding := Finding{
+			Kind:        FindingBucketClaimUIDMismatch,
+			BucketClaim: bucketClaimKey(bucketClaim),
+			Bucket:      bucket.ObjectMeta.Name,
+			Message: fmt.Sprintf("bucket is bound to bucket claim UID %s, the bucket claim has UID %s",
+				ref.UID, bucketClaim.ObjectMeta.UID),
+		}
+		c.repairWith(&finding, func() error {
+			err := NewGarbageCollector(c.claims, false).collectBucket(ctx, bucket)
+			if kubeerrors.IsNotFound(err) {
+				// Deleted since it was listed
+				return nil
+			}
+			return err
+		})
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
+
+func (c *ConsistencyChecker) checkBucketClaim(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, buckets map[string]*v1alpha1.Bucket) *Finding {
+	bucketName := bucketClaim.Status.BucketName
+	hasFinalizer := controllerutil.ContainsFinalizer(bucketClaim, util.BucketClaimFinalizer)
+
+	finding := &Finding{
+		BucketClaim: bucketClaimKey(bucketClaim),
+		Bucket:      bucketName,
+	}
+
+	if bucketName == "" {
+		if !hasFinalizer {
+			return nil
+		}
+		finding.Kind = FindingFinalizerWithoutStatus
+		finding.Message = "bucket claim has the finalizer but no bucket in its status"
+		c.repairWith(finding, func() error {
+			return c.claims.provisionBucketClaimOperation(ctx, bucketClaim)
+		})
+		return finding
+	}
+
+	bucket, ok := buckets[bucketName]
+	switch {
+	case !ok:
+		finding.Kind = FindingMissingBucket
+		finding.Message = "bucket in the status of the bucket claim does not exist"
+	case bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != bucketClaim.ObjectMeta.UID:
+		finding.Kind = FindingBucketNotBoundToClaim
+		finding.Message = "bucket in the status of the bucket claim is not bound to it"
+	case !hasFinalizer:
+		finding.Kind = FindingStatusWithoutFinalizer
+		finding.Message = "bucket claim has a bucket in its status but not the finalizer"
+		c.repairWith(finding, func() error {
+			_, err := c.claims.protectBucketClaim(ctx, bucketClaim, "")
+			return err
+		})
+		return finding
+	default:
+		return nil
+	}
+
+	// Clearing the status of a bucketClaim binding an existing bucket would
+	// only lose track of that bucket, it is left to an administrator
+	if existingBucketName := bucketClaim.Spec.ExistingBucketName; existingBucketName != "" {
+		c.repairWith(finding, func() error {
+			return fmt.Errorf("bucket claim binds existing bucket %q, it cannot be provisioned again", existingBucketName)
+		})
+		return finding
+	}
+
+	// The bucketClaim is provisioned again from scratch, under a new name if
+	// the reserved one is taken by another bucketClaim
+	c.repairWith(finding, func() error {
+		if finding.Kind == FindingBucketNotBoundToClaim && bucketClaim.ObjectMeta.Annotations[util.BucketNameAnnotation] == bucketName {
+			var err error
+			bucketClaim, err = c.claims.patchBucketClaim(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+				delete(bucketClaim.ObjectMeta.Annotations, util.BucketNameAnnotation)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		bucketClaim, err := c.claims.patchBucketClaimStatus(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+			bucketClaim.Status.BucketName = ""
+			bucketClaim.Status.BucketReady = false
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return c.claims.provisionBucketClaimOperation(ctx, bucketClaim)
+	})
+	return finding
+}
+
+// repairWith applies repair to finding if repairs are enabled. The finding is
+// only repaired if repair succeeds.
+func (c *ConsistencyChecker) repairWith(finding *Finding, repair func() error) {
+	if !c.repair {
+		return
+	}
+
+	err := repair()
+	if err != nil {
+		klog.V(3).ErrorS(err, "Repair error", "kind", finding.Kind, "bucketClaim", finding.BucketClaim, "bucket", finding.Bucket)
+		finding.RepairError = err.Error()
+		return
+	}
+	finding.Repaired = true
+}
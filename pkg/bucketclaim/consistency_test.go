@@ -0,0 +1,176 @@
+package bucketclaim
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test detection and repair of inconsistent bucketClaims and buckets
+func TestConsistencyChecker(t *testing.T) {
+	t.Parallel()
+
+	newClaim := func(name string, bucketName string, finalizer bool) *v1alpha1.BucketClaim {
+		bucketClaim := bucketClaim1.DeepCopy()
+		bucketClaim.Name = name
+		bucketClaim.UID = kubetypes.UID(name + "-uid")
+		bucketClaim.Status.BucketName = bucketName
+		if finalizer {
+			bucketClaim.Finalizers = []string{util.BucketClaimFinalizer}
+		}
+		return bucketClaim
+	}
+	newBucket := func(name string, bucketClaim *v1alpha1.BucketClaim, uid kubetypes.UID) *v1alpha1.Bucket {
+		return &v1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.BucketSpec{
+				DriverName:      "sample.cosi.driver",
+				BucketClassName: "classgold",
+				DeletionPolicy:  v1alpha1.DeletionPolicyDelete,
+				BucketClaim: &v1.ObjectReference{
+					Name:      bucketClaim.Name,
+					Namespace: bucketClaim.Namespace,
+					UID:       uid,
+				},
+			},
+		}
+	}
+
+	healthy := newClaim("healthy", "bucket-healthy", true)
+	missingBucket := newClaim("missing-bucket", "bucket-gone", true)
+	noStatus := newClaim("no-status", "", true)
+	noFinalizer := newClaim("no-finalizer", "bucket-no-finalizer", false)
+	otherBound := newClaim("other-bound", "bucket-healthy", true)
+
+	objects := []runtime.Object{
+		goldClass.DeepCopy(),
+		healthy, missingBucket, noStatus, noFinalizer, otherBound,
+		newBucket("bucket-healthy", healthy, healthy.UID),
+		newBucket("bucket-no-finalizer", noFinalizer, noFinalizer.UID),
+		newBucket("bucket-stale", healthy, "deleted-uid"),
+	}
+
+	expected := map[string]string{
+		FindingMissingBucket:          "default/missing-bucket",
+		FindingFinalizerWithoutStatus: "default/no-status",
+		FindingStatusWithoutFinalizer: "default/no-finalizer",
+		FindingBucketNotBoundToClaim:  "default/other-bound",
+		FindingBucketClaimUIDMismatch: "default/healthy",
+	}
+
+	for _, repair := range []bool{false, true} {
+		ctx := context.TODO()
+		client := fakebucketclientset.NewSimpleClientset(objects...)
+		listener := NewBucketClaimListener()
+		listener.InitializeBucketClient(client)
+
+		findings, err := NewConsistencyChecker(listener, repair).Check(ctx)
+		if err != nil {
+			t.Fatalf("Error occurred when checking consistency: %v", err)
+		}
+
+		kinds := []string{}
+		for _, finding := range findings {
+			kinds = append(kinds, finding.Kind)
+			if expected[finding.Kind] != finding.BucketClaim {
+				t.Errorf("unexpected finding %+v", finding)
+			}
+			if repair && !finding.Repaired {
+				t.Errorf("expected finding to be repaired %+v", finding)
+			}
+			if !repair && finding.Repaired {
+				t.Errorf("expected finding not to be repaired %+v", finding)
+			}
+		}
+		sort.Strings(kinds)
+		if len(kinds) != len(expected) {
+			t.Fatalf("expected %d findings got %v", len(expected), kinds)
+		}
+
+		if !repair {
+			continue
+		}
+
+		_, err = client.ObjectstorageV1alpha1().Buckets().Get(ctx, "bucket-stale", metav1.GetOptions{})
+		if !kubeerrors.IsNotFound(err) {
+			t.Errorf("expected stale Bucket to be deleted got %v", err)
+		}
+
+		for _, name := range []string{"missing-bucket", "no-status", "other-bound"} {
+			bucketClaim, err := client.ObjectstorageV1alpha1().BucketClaims("default").Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+			}
+			if bucketClaim.Status.BucketName == "" || bucketClaim.Status.BucketName == "bucket-gone" || bucketClaim.Status.BucketName == "bucket-healthy" {
+				t.Errorf("expected BucketClaim %s to be provisioned again got status %+v", name, bucketClaim.Status)
+			}
+		}
+
+		bucketClaim, err := client.ObjectstorageV1alpha1().BucketClaims("default").Get(ctx, "no-finalizer", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+		}
+		if len(bucketClaim.Finalizers) != 1 {
+			t.Errorf("expected BucketClaim finalizer to be added got %v", bucketClaim.Finalizers)
+		}
+	}
+}
+
+// Test that findings are only repaired when the bucketClaim is provisioned again
+func TestConsistencyCheckerRepairFailure(t *testing.T) {
+	t.Parallel()
+
+	existing := bucketClaim1.DeepCopy()
+	existing.Name = "existing"
+	existing.UID = "existing-uid"
+	existing.Spec.BucketClassName = ""
+	existing.Spec.ExistingBucketName = "bucket-gone"
+	existing.Status.BucketName = "bucket-gone"
+	existing.Status.BucketReady = true
+	existing.Finalizers = []string{util.BucketClaimFinalizer}
+
+	missingClass := bucketClaim1.DeepCopy()
+	missingClass.Name = "missing-class"
+	missingClass.UID = "missing-class-uid"
+	missingClass.Spec.BucketClassName = "missing"
+	missingClass.Status.BucketName = "bucket-gone"
+	missingClass.Finalizers = []string{util.BucketClaimFinalizer}
+
+	ctx := context.TODO()
+	client := fakebucketclientset.NewSimpleClientset(existing, missingClass)
+	listener := NewBucketClaimListener()
+	listener.InitializeBucketClient(client)
+
+	findings, err := NewConsistencyChecker(listener, true).Check(ctx)
+	if err != nil {
+		t.Fatalf("Error occurred when checking consistency: %v", err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings got %+v", findings)
+	}
+	for _, finding := range findings {
+		if finding.Kind != FindingMissingBucket {
+			t.Errorf("unexpected finding %+v", finding)
+		}
+		if finding.Repaired || finding.RepairError == "" {
+			t.Errorf("expected finding not to be repaired %+v", finding)
+		}
+	}
+
+	bucketClaim, err := client.ObjectstorageV1alpha1().BucketClaims("default").Get(ctx, "existing", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if bucketClaim.Status.BucketName != "bucket-gone" || !bucketClaim.Status.BucketReady {
+		t.Errorf("expected status of BucketClaim with existing bucket to be kept got %+v", bucketClaim.Status)
+	}
+}
@@ -0,0 +1,135 @@
+package bucketclaim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// resolveBucketClassName returns the name of the bucketClass to provision the
+// bucketClaim with.
+//
+// A bucketClaim without a BucketClassName uses the default bucketClass of its
+// namespace if the namespace names one, and the cluster default bucketClass
+// otherwise. The configured default bucketClass is used when no bucketClass
+// is marked as the cluster default.
+func (b *BucketClaimListener) resolveBucketClassName(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) (string, error) {
+	if bucketClaim.Spec.BucketClassName != "" {
+		return bucketClaim.Spec.BucketClassName, nil
+	}
+
+	if b.kubeClient != nil {
+		namespace, err := b.kubeClient.CoreV1().Namespaces().Get(ctx, bucketClaim.ObjectMeta.Namespace, metav1.GetOptions{})
+		if err != nil && !kubeerrors.IsNotFound(err) {
+			return "", err
+		}
+		if err == nil {
+			if name := namespace.ObjectMeta.Annotations[util.DefaultBucketClassAnnotation]; name != "" {
+				klog.V(5).InfoS("Using namespace default BucketClass",
+					"bucketClaim", bucketClaim.ObjectMeta.Name,
+					"ns", bucketClaim.ObjectMeta.Namespace,
+					"bucketClass", name)
+				return name, nil
+			}
+		}
+	}
+
+	bucketClasses, err := b.listBucketClasses(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	defaults := []string{}
+	for _, bucketClass := range bucketClasses {
+		if isDefaultBucketClass(&bucketClass) {
+			defaults = append(defaults, bucketClass.ObjectMeta.Name)
+		}
+	}
+
+	switch len(defaults) {
+	case 0:
+		b.settingsLock.RLock()
+		defer b.settingsLock.RUnlock()
+
+		if b.defaultBucketClassName != "" {
+			klog.V(5).InfoS("Using configured default BucketClass",
+				"bucketClaim", bucketClaim.ObjectMeta.Name,
+				"ns", bucketClaim.ObjectMeta.Namespace,
+				"bucketClass", b.defaultBucketClassName)
+			return b.defaultBucketClassName, nil
+		}
+		return "", util.ErrInvalidBucketClass
+	case 1:
+		klog.V(5).InfoS("Using cluster default BucketClass",
+			"bucketClaim", bucketClaim.ObjectMeta.Name,
+			"ns", bucketClaim.ObjectMeta.Namespace,
+			"bucketClass", defaults[0])
+		return defaults[0], nil
+	default:
+		return "", fmt.Errorf("%w: %v", util.ErrMultipleDefaultBucketClasses, defaults)
+	}
+}
+
+// isDefaultBucketClass reports whether bucketClass is marked as the cluster default
+func isDefaultBucketClass(bucketClass *v1alpha1.BucketClass) bool {
+	return bucketClass.ObjectMeta.Annotations[util.IsDefaultBucketClassAnnotation] == "true"
+}
+
+// DefaultBucketClaim fills in the fields a new bucketClaim leaves empty: the
+// bucketClass resolved as resolveBucketClassName does, the default protocols
+// of that bucketClass and the labels naming the bucketClass and its driver.
+// Existing labels are kept. A defaulting webhook can use it so that the
+// stored bucketClaim shows what the controller will provision.
+//
+// BucketClaims of an existing bucket and bucketClaims whose bucketClass
+// cannot be resolved are left for validation to report on.
+func (b *BucketClaimListener) DefaultBucketClaim(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) error {
+	if bucketClaim.Spec.ExistingBucketName != "" {
+		return nil
+	}
+
+	bucketClassName, err := b.resolveBucketClassName(ctx, bucketClaim)
+	if errors.Is(err, util.ErrInvalidBucketClass) || errors.Is(err, util.ErrMultipleDefaultBucketClasses) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	bucketClass, err := b.getBucketClass(ctx, bucketClassName)
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	bucketClaim.Spec.BucketClassName = bucketClassName
+
+	if len(bucketClaim.Spec.Protocols) == 0 {
+		protocols, err := defaultProtocols(bucketClass)
+		if err != nil {
+			return err
+		}
+		bucketClaim.Spec.Protocols = protocols
+	}
+
+	setDefaultLabel(bucketClaim, util.BucketClassLabel, bucketClassName)
+	setDefaultLabel(bucketClaim, util.DriverNameLabel, bucketClass.DriverName)
+	return nil
+}
+
+// setDefaultLabel sets label to value on bucketClaim unless the label is
+// already set or value is not a valid label value
+func setDefaultLabel(bucketClaim *v1alpha1.BucketClaim, label, value string) {
+	if _, ok := bucketClaim.ObjectMeta.Labels[label]; ok || len(validation.IsValidLabelValue(value)) > 0 {
+		return
+	}
+	if bucketClaim.ObjectMeta.Labels == nil {
+		bucketClaim.ObjectMeta.Labels = map[string]string{}
+	}
+	bucketClaim.ObjectMeta.Labels[label] = value
+}
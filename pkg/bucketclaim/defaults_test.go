@@ -0,0 +1,203 @@
+package bucketclaim
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test selection of the default bucketClass
+func TestResolveBucketClassName(t *testing.T) {
+	t.Parallel()
+
+	defaultClass := func(name string) *v1alpha1.BucketClass {
+		bucketClass := goldClass.DeepCopy()
+		bucketClass.Name = name
+		bucketClass.Annotations = map[string]string{util.IsDefaultBucketClassAnnotation: "true"}
+		return bucketClass
+	}
+
+	annotatedNamespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{util.DefaultBucketClassAnnotation: "classsilver"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name                string
+		bucketObjects       []runtime.Object
+		kubeObjects         []runtime.Object
+		className           string
+		configuredClassName string
+		expectedName        string
+		expectedErr         error
+	}{
+		{
+			name:          "Explicit",
+			bucketObjects: []runtime.Object{defaultClass("classdefault")},
+			className:     "classgold",
+			expectedName:  "classgold",
+		},
+		{
+			name:          "ClusterDefault",
+			bucketObjects: []runtime.Object{goldClass.DeepCopy(), defaultClass("classdefault")},
+			expectedName:  "classdefault",
+		},
+		{
+			name:          "NamespaceDefault",
+			bucketObjects: []runtime.Object{defaultClass("classdefault")},
+			kubeObjects:   []runtime.Object{annotatedNamespace},
+			expectedName:  "classsilver",
+		},
+		{
+			name:          "NoDefault",
+			bucketObjects: []runtime.Object{goldClass.DeepCopy()},
+			expectedErr:   util.ErrInvalidBucketClass,
+		},
+		{
+			name:                "ConfiguredDefault",
+			bucketObjects:       []runtime.Object{goldClass.DeepCopy()},
+			configuredClassName: "classgold",
+			expectedName:        "classgold",
+		},
+		{
+			name:                "ClusterDefaultOverConfigured",
+			bucketObjects:       []runtime.Object{goldClass.DeepCopy(), defaultClass("classdefault")},
+			configuredClassName: "classgold",
+			expectedName:        "classdefault",
+		},
+		{
+			name:          "MultipleDefaults",
+			bucketObjects: []runtime.Object{defaultClass("classdefault"), defaultClass("classdefault2")},
+			expectedErr:   util.ErrMultipleDefaultBucketClasses,
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			listener := NewBucketClaimListener(WithDefaultBucketClassName(tc.configuredClassName))
+			listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset(tc.kubeObjects...))
+			listener.InitializeBucketClient(fakebucketclientset.NewSimpleClientset(tc.bucketObjects...))
+
+			bucketClaim := bucketClaim1.DeepCopy()
+			bucketClaim.Spec.BucketClassName = tc.className
+
+			name, err := listener.resolveBucketClassName(context.TODO(), bucketClaim)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v got %v", tc.expectedErr, err)
+			}
+			if name != tc.expectedName {
+				t.Errorf("expected bucketClass %q got %q", tc.expectedName, name)
+			}
+		})
+	}
+}
+
+// Test filling in the fields new bucketClaims leave empty
+func TestDefaultBucketClaim(t *testing.T) {
+	t.Parallel()
+
+	defaultClass := goldClass.DeepCopy()
+	defaultClass.Name = "classdefault"
+	defaultClass.Annotations = map[string]string{util.IsDefaultBucketClassAnnotation: "true"}
+	defaultClass.Parameters = map[string]string{util.DefaultProtocolsParameter: "S3, Azure"}
+
+	for _, tc := range []struct {
+		name              string
+		mutate            func(*v1alpha1.BucketClaim)
+		expectedClassName string
+		expectedProtocols []v1alpha1.Protocol
+		expectedLabels    map[string]string
+	}{
+		{
+			name: "Empty",
+			mutate: func(b *v1alpha1.BucketClaim) {
+				b.Spec.BucketClassName = ""
+				b.Spec.Protocols = nil
+			},
+			expectedClassName: "classdefault",
+			expectedProtocols: []v1alpha1.Protocol{v1alpha1.ProtocolS3, v1alpha1.ProtocolAzure},
+			expectedLabels: map[string]string{
+				util.BucketClassLabel: "classdefault",
+				util.DriverNameLabel:  "sample.cosi.driver",
+			},
+		},
+		{
+			name: "ExplicitFields",
+			mutate: func(b *v1alpha1.BucketClaim) {
+				b.Labels = map[string]string{util.BucketClassLabel: "custom"}
+			},
+			expectedClassName: "classgold",
+			expectedProtocols: bucketClaim1.Spec.Protocols,
+			expectedLabels: map[string]string{
+				util.BucketClassLabel: "custom",
+				util.DriverNameLabel:  "sample.cosi.driver",
+			},
+		},
+		{
+			name: "ClassWithoutDefaultProtocols",
+			mutate: func(b *v1alpha1.BucketClaim) {
+				b.Spec.Protocols = nil
+			},
+			expectedClassName: "classgold",
+			expectedLabels: map[string]string{
+				util.BucketClassLabel: "classgold",
+				util.DriverNameLabel:  "sample.cosi.driver",
+			},
+		},
+		{
+			name: "ExistingBucket",
+			mutate: func(b *v1alpha1.BucketClaim) {
+				b.Spec.BucketClassName = ""
+				b.Spec.ExistingBucketName = "bucket1"
+			},
+			expectedProtocols: bucketClaim1.Spec.Protocols,
+		},
+		{
+			name: "ClassNotFound",
+			mutate: func(b *v1alpha1.BucketClaim) {
+				b.Spec.BucketClassName = "classsilver"
+			},
+			expectedClassName: "classsilver",
+			expectedProtocols: bucketClaim1.Spec.Protocols,
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			listener := NewBucketClaimListener()
+			listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+			listener.InitializeBucketClient(fakebucketclientset.NewSimpleClientset(goldClass.DeepCopy(), defaultClass.DeepCopy()))
+
+			bucketClaim := bucketClaim1.DeepCopy()
+			tc.mutate(bucketClaim)
+			if err := listener.DefaultBucketClaim(context.TODO(), bucketClaim); err != nil {
+				t.Fatalf("Error occurred when defaulting BucketClaim: %v", err)
+			}
+
+			if bucketClaim.Spec.BucketClassName != tc.expectedClassName {
+				t.Errorf("expected bucketClass %q got %q", tc.expectedClassName, bucketClaim.Spec.BucketClassName)
+			}
+			if !reflect.DeepEqual(bucketClaim.Spec.Protocols, tc.expectedProtocols) {
+				t.Errorf("expected protocols %v got %v", tc.expectedProtocols, bucketClaim.Spec.Protocols)
+			}
+			if !reflect.DeepEqual(bucketClaim.Labels, tc.expectedLabels) {
+				t.Errorf("expected labels %v got %v", tc.expectedLabels, bucketClaim.Labels)
+			}
+		})
+	}
+}
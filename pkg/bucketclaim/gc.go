@@ -0,0 +1,106 @@
+package bucketclaim
+
+import (
+	"context"
+	"time"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+)
+
+// GarbageCollector applies the DeletionPolicy of buckets whose bucketClaim was
+// deleted without the BucketClaimListener seeing it, e.g. while the
+// controller was down.
+type GarbageCollector struct {
+	claims *BucketClaimListener
+	dryRun bool
+}
+
+// NewGarbageCollector returns a GarbageCollector acting through the clients
+// of claims. In dry-run mode it only logs what it would do.
+func NewGarbageCollector(claims *BucketClaimListener, dryRun bool) *GarbageCollector {
+	return &GarbageCollector{
+		claims: claims,
+		dryRun: dryRun,
+	}
+}
+
+// Run collects orphaned buckets every period until ctx is cancelled. Passes
+// are skipped while isLeader reports that another replica is leading.
+func (g *GarbageCollector) Run(ctx context.Context, period time.Duration, isLeader func() bool) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if !isLeader() {
+			klog.V(5).InfoS("Not the leader, skipping orphaned Bucket collection")
+			return
+		}
+		if err := g.Collect(ctx); err != nil {
+			klog.ErrorS(err, "Orphaned Bucket collection error")
+		}
+	}, period)
+}
+
+// Collect releases or deletes, according to their DeletionPolicy, the buckets
+// bound to a bucketClaim that no longer exists.
+func (g *GarbageCollector) Collect(ctx context.Context) error {
+	// Buckets are listed before bucketClaims, so that the bucketClaim of a
+	// bucket created in between is always seen.
+	buckets, err := g.claims.buckets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	bucketClaims, err := g.claims.bucketClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	liveClaims := map[types.UID]bool{}
+	for _, bucketClaim := range bucketClaims.Items {
+		liveClaims[bucketClaim.ObjectMeta.UID] = true
+	}
+
+	for i := range buckets.Items {
+		bucket := &buckets.Items[i]
+		ref := bucket.Spec.BucketClaim
+		if ref == nil || ref.UID == "" || liveClaims[ref.UID] || !bucket.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		err := g.collectBucket(ctx, bucket)
+		if err != nil && !kubeerrors.IsNotFound(err) {
+			klog.ErrorS(err, "Orphaned Bucket collection error", "bucket", bucket.ObjectMeta.Name)
+		}
+	}
+	return nil
+}
+
+func (g *GarbageCollector) collectBucket(ctx context.Context, bucket *v1alpha1.Bucket) error {
+	ref := bucket.Spec.BucketClaim
+	logValues := []interface{}{
+		"bucket", bucket.ObjectMeta.Name,
+		"bucketClaim", ref.Name,
+		"ns", ref.Namespace,
+		"uid", ref.UID,
+		"deletionPolicy", bucket.Spec.DeletionPolicy,
+		"dryRun", g.dryRun,
+	}
+
+	if bucket.Spec.DeletionPolicy == v1alpha1.DeletionPolicyRetain {
+		klog.InfoS("Releasing orphaned Bucket", logValues...)
+		if g.dryRun {
+			return nil
+		}
+		return g.claims.unbindBucket(ctx, bucket)
+	}
+
+	klog.InfoS("Deleting orphaned Bucket", logValues...)
+	if g.dryRun {
+		return nil
+	}
+	return g.claims.buckets().Delete(ctx, bucket.ObjectMeta.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &bucket.ObjectMeta.UID},
+	})
+}
@@ -0,0 +1,108 @@
+package bucketclaim
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test collection of buckets whose bucketClaim no longer exists
+func TestGarbageCollector(t *testing.T) {
+	t.Parallel()
+
+	liveClaim := bucketClaim1.DeepCopy()
+	liveClaim.UID = "live-uid"
+
+	newBucket := func(name string, policy v1alpha1.DeletionPolicy, ref *v1.ObjectReference) *v1alpha1.Bucket {
+		return &v1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.BucketSpec{
+				DriverName:     "sample.cosi.driver",
+				DeletionPolicy: policy,
+				BucketClaim:    ref,
+			},
+		}
+	}
+	claimRef := func(name string, uid string) *v1.ObjectReference {
+		return &v1.ObjectReference{Name: name, Namespace: "default", UID: kubetypes.UID(uid)}
+	}
+
+	objects := []runtime.Object{
+		liveClaim,
+		newBucket("bound", v1alpha1.DeletionPolicyDelete, claimRef(liveClaim.Name, "live-uid")),
+		newBucket("orphan-delete", v1alpha1.DeletionPolicyDelete, claimRef("gone", "gone-uid")),
+		newBucket("orphan-retain", v1alpha1.DeletionPolicyRetain, claimRef("gone", "gone-uid2")),
+		newBucket("reserved", v1alpha1.DeletionPolicyDelete, claimRef("future", "")),
+		newBucket("unbound", v1alpha1.DeletionPolicyDelete, nil),
+	}
+
+	for _, tc := range []struct {
+		name     string
+		dryRun   bool
+		deleted  []string
+		released []string
+	}{
+		{
+			name:     "Collect",
+			deleted:  []string{"orphan-delete"},
+			released: []string{"orphan-retain"},
+		},
+		{
+			name:   "DryRun",
+			dryRun: true,
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.TODO()
+			client := fakebucketclientset.NewSimpleClientset(objects...)
+			listener := NewBucketClaimListener()
+			listener.InitializeBucketClient(client)
+
+			if err := NewGarbageCollector(listener, tc.dryRun).Collect(ctx); err != nil {
+				t.Fatalf("Error occurred when collecting Buckets: %v", err)
+			}
+
+			deleted := map[string]bool{}
+			for _, name := range tc.deleted {
+				deleted[name] = true
+			}
+			released := map[string]bool{}
+			for _, name := range tc.released {
+				released[name] = true
+			}
+
+			for _, name := range []string{"bound", "orphan-delete", "orphan-retain", "reserved", "unbound"} {
+				bucket, err := client.ObjectstorageV1alpha1().Buckets().Get(ctx, name, metav1.GetOptions{})
+				if deleted[name] {
+					if !kubeerrors.IsNotFound(err) {
+						t.Errorf("expected Bucket %s to be deleted got %v", name, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("expected Bucket %s to exist got %v", name, err)
+				}
+
+				isReleased := bucket.ObjectMeta.Annotations[util.BucketReleasedAnnotation] == "true"
+				if released[name] != isReleased {
+					t.Errorf("expected Bucket %s released %v got %v", name, released[name], isReleased)
+				}
+				if released[name] && bucket.Spec.BucketClaim != nil {
+					t.Errorf("expected released Bucket %s to be unbound got %v", name, bucket.Spec.BucketClaim)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package bucketclaim
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Names of the built-in bucket naming strategies
+const (
+	// NamingStrategyConcat appends the bucketClaim UID to the bucketClass name.
+	// This is the historical behaviour of the controller.
+	NamingStrategyConcat = "concat"
+	// NamingStrategyClassUID joins the bucketClass name and bucketClaim UID with a dash.
+	NamingStrategyClassUID = "class-uid"
+	// NamingStrategyHashed generates a fixed-length name from a hash of the
+	// bucketClass name and bucketClaim UID.
+	NamingStrategyHashed = "hashed"
+	// NamingStrategyTemplate renders a user supplied Go template.
+	NamingStrategyTemplate = "template"
+)
+
+const (
+	hashedNamePrefix = "cosi-"
+	hashedNameLength = 32
+)
+
+// BucketNamer generates the name of the Bucket provisioned for a bucketClaim
+type BucketNamer interface {
+	BucketName(bucketClaim *v1alpha1.BucketClaim, bucketClassName string) (string, error)
+}
+
+// BucketNameTemplateData is the data available to NamingStrategyTemplate templates
+type BucketNameTemplateData struct {
+	Namespace string
+	Name      string
+	Class     string
+	UID       string
+}
+
+// NewBucketNamer returns the BucketNamer for the given strategy. The template
+// is only used by NamingStrategyTemplate.
+func NewBucketNamer(strategy, tmpl string) (BucketNamer, error) {
+	switch strategy {
+	case "", NamingStrategyConcat:
+		return concatNamer{}, nil
+	case NamingStrategyClassUID:
+		return classUIDNamer{}, nil
+	case NamingStrategyHashed:
+		return hashedNamer{}, nil
+	case NamingStrategyTemplate:
+		if tmpl == "" {
+			return nil, fmt.Errorf("%w: strategy %q requires a template", util.ErrInvalidBucketNamingStrategy, strategy)
+		}
+		t, err := template.New("bucketName").Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", util.ErrInvalidBucketNamingStrategy, err)
+		}
+		return templateNamer{template: t}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", util.ErrInvalidBucketNamingStrategy, strategy)
+	}
+}
+
+type concatNamer struct{}
+
+func (concatNamer) BucketName(bucketClaim *v1alpha1.BucketClaim, bucketClassName string) (string, error) {
+	return validateBucketName(bucketClassName + string(bucketClaim.ObjectMeta.UID))
+}
+
+type classUIDNamer struct{}
+
+func (classUIDNamer) BucketName(bucketClaim *v1alpha1.BucketClaim, bucketClassName string) (string, error) {
+	return validateBucketName(bucketClassName + "-" + string(bucketClaim.ObjectMeta.UID))
+}
+
+type hashedNamer struct{}
+
+func (hashedNamer) BucketName(bucketClaim *v1alpha1.BucketClaim, bucketClassName string) (string, error) {
+	sum := sha256.Sum256([]byte(bucketClassName + "/" + string(bucketClaim.ObjectMeta.UID)))
+	return validateBucketName(hashedNamePrefix + hex.EncodeToString(sum[:])[:hashedNameLength])
+}
+
+type templateNamer struct {
+	template *template.Template
+}
+
+func (t templateNamer) BucketName(bucketClaim *v1alpha1.BucketClaim, bucketClassName string) (string, error) {
+	var buf bytes.Buffer
+	err := t.template.Execute(&buf, BucketNameTemplateData{
+		Namespace: bucketClaim.ObjectMeta.Namespace,
+		Name:      bucketClaim.ObjectMeta.Name,
+		Class:     bucketClassName,
+		UID:       string(bucketClaim.ObjectMeta.UID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", util.ErrInvalidBucketName, err)
+	}
+	return validateBucketName(strings.TrimSpace(buf.String()))
+}
+
+// validateBucketName checks that name can be used as the name of a Bucket object
+func validateBucketName(name string) (string, error) {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("%w: %q: %s", util.ErrInvalidBucketName, name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// bucketNamerFor returns the BucketNamer selected by the bucketClass parameters,
+// falling back to the namer the listener was configured with.
+func (b *BucketClaimListener) bucketNamerFor(bucketClass *v1alpha1.BucketClass) (BucketNamer, error) {
+	strategy, ok := bucketClass.Parameters[util.BucketNamingStrategyParameter]
+	if !ok {
+		b.settingsLock.RLock()
+		defer b.settingsLock.RUnlock()
+
+		return b.bucketNamer, nil
+	}
+	return NewBucketNamer(strategy, bucketClass.Parameters[util.BucketNameTemplateParameter])
+}
+
+// bucketNameRules return the naming rules of the object storage backends of
+// a protocol that a bucket name breaks
+var bucketNameRules = map[v1alpha1.Protocol]func(name string) []string{
+	v1alpha1.ProtocolS3:    s3BucketNameErrors,
+	v1alpha1.ProtocolAzure: azureContainerNameErrors,
+	v1alpha1.ProtocolGCP:   gcsBucketNameErrors,
+}
+
+var (
+	s3BucketNameRegexp       = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?$`)
+	azureContainerNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9]|-[a-z0-9])*$`)
+	gcsBucketNameRegexp      = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+	gcsGoogleRegexp          = regexp.MustCompile(`g[o0][o0]g[l1]e`)
+)
+
+// s3BucketNameErrors checks name against the S3 bucket naming rules
+func s3BucketNameErrors(name string) []string {
+	errs := []string{}
+	if len(name) < 3 || len(name) > 63 {
+		errs = append(errs, "must be between 3 and 63 characters")
+	}
+	if !s3BucketNameRegexp.MatchString(name) {
+		errs = append(errs, "must consist of lowercase letters, digits, dots and dashes, and start and end with a letter or digit")
+	}
+	if strings.Contains(name, "..") {
+		errs = append(errs, "must not contain adjacent dots")
+	}
+	if net.ParseIP(name) != nil {
+		errs = append(errs, "must not be formatted as an IP address")
+	}
+	if strings.HasPrefix(name, "xn--") || strings.HasSuffix(name, "-s3alias") || strings.HasSuffix(name, "--ol-s3") {
+		errs = append(errs, `must not start with "xn--" or end with "-s3alias" or "--ol-s3"`)
+	}
+	return errs
+}
+
+// azureContainerNameErrors checks name against the Azure blob container
+// naming rules
+func azureContainerNameErrors(name string) []string {
+	errs := []string{}
+	if len(name) < 3 || len(name) > 63 {
+		errs = append(errs, "must be between 3 and 63 characters")
+	}
+	if !azureContainerNameRegexp.MatchString(name) {
+		errs = append(errs, "must consist of lowercase letters, digits and dashes, start and end with a letter or digit and not contain adjacent dashes")
+	}
+	return errs
+}
+
+// gcsBucketNameErrors checks name against the Google Cloud Storage bucket
+// naming rules. Names containing dots may be up to 222 characters long, with
+// every dot separated component at most 63 characters long.
+func gcsBucketNameErrors(name string) []string {
+	errs := []string{}
+	maxLength := 63
+	if strings.Contains(name, ".") {
+		maxLength = 222
+	}
+	if len(name) < 3 || len(name) > maxLength {
+		errs = append(errs, fmt.Sprintf("must be between 3 and %d characters", maxLength))
+	}
+	for _, component := range strings.Split(name, ".") {
+		if len(component) > 63 {
+			errs = append(errs, "must not contain dot separated components longer than 63 characters")
+			break
+		}
+	}
+	if !gcsBucketNameRegexp.MatchString(name) {
+		errs = append(errs, "must consist of lowercase letters, digits, dots, dashes and underscores, and start and end with a letter or digit")
+	}
+	if net.ParseIP(name) != nil {
+		errs = append(errs, "must not be formatted as an IP address")
+	}
+	if strings.HasPrefix(name, "goog") || gcsGoogleRegexp.MatchString(name) {
+		errs = append(errs, `must not start with "goog" or contain "google" or close misspellings of it`)
+	}
+	return errs
+}
+
+// checkProtocolBucketName checks name against the naming rules of every
+// protocol in protocols
+func checkProtocolBucketName(name string, protocols []v1alpha1.Protocol) error {
+	for _, protocol := range protocols {
+		rules, ok := bucketNameRules[protocol]
+		if !ok {
+			continue
+		}
+		if errs := rules(name); len(errs) > 0 {
+			return fmt.Errorf("%w: %q breaks the %s bucket naming rules: %s",
+				util.ErrBucketNameNotCompliant, name, protocol, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// compliantBucketName checks a newly generated bucket name against the naming
+// rules of the protocols bucketClaim requests, so that invalid names are
+// reported before the driver is asked to create the bucket.
+//
+// Names that break the rules are replaced by the name NamingStrategyHashed
+// generates, which complies with the rules of every protocol. Names rendered
+// from a template are what the bucketClass asks for and are not replaced.
+func (b *BucketClaimListener) compliantBucketName(bucketClaim *v1alpha1.BucketClaim, bucketClassName string, namer BucketNamer, name string) (string, error) {
+	err := checkProtocolBucketName(name, bucketClaim.Spec.Protocols)
+	if err == nil {
+		return name, nil
+	}
+	if _, ok := namer.(templateNamer); ok {
+		return "", err
+	}
+
+	fallback, fallbackErr := hashedNamer{}.BucketName(bucketClaim, bucketClassName)
+	if fallbackErr == nil {
+		fallbackErr = checkProtocolBucketName(fallback, bucketClaim.Spec.Protocols)
+	}
+	if fallbackErr != nil {
+		return "", err
+	}
+
+	b.recordEvent(bucketClaim, v1.EventTypeWarning, util.BucketNameFallback, "%v, using %q instead", err, fallback)
+	return fallback, nil
+}
@@ -0,0 +1,220 @@
+package bucketclaim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test the built-in bucket naming strategies
+func TestBucketNamer(t *testing.T) {
+	t.Parallel()
+
+	bucketClaim := &v1alpha1.BucketClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bucketclaim1",
+			Namespace: "default",
+			UID:       "12345-67890",
+		},
+	}
+
+	for _, tc := range []struct {
+		name         string
+		strategy     string
+		template     string
+		expectedName string
+		expectedErr  error
+	}{
+		{
+			name:         "Default",
+			expectedName: "classgold12345-67890",
+		},
+		{
+			name:         "Concat",
+			strategy:     NamingStrategyConcat,
+			expectedName: "classgold12345-67890",
+		},
+		{
+			name:         "ClassUID",
+			strategy:     NamingStrategyClassUID,
+			expectedName: "classgold-12345-67890",
+		},
+		{
+			name:         "Hashed",
+			strategy:     NamingStrategyHashed,
+			expectedName: "cosi-b94200126e2e765f89ffc4b622fb7f7f",
+		},
+		{
+			name:         "Template",
+			strategy:     NamingStrategyTemplate,
+			template:     "{{.Namespace}}-{{.Name}}-{{.Class}}",
+			expectedName: "default-bucketclaim1-classgold",
+		},
+		{
+			name:        "TemplateInvalidName",
+			strategy:    NamingStrategyTemplate,
+			template:    "{{.Namespace}}_{{.Name}}",
+			expectedErr: util.ErrInvalidBucketName,
+		},
+		{
+			name:        "TemplateMissing",
+			strategy:    NamingStrategyTemplate,
+			expectedErr: util.ErrInvalidBucketNamingStrategy,
+		},
+		{
+			name:        "Unknown",
+			strategy:    "unknown",
+			expectedErr: util.ErrInvalidBucketNamingStrategy,
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			namer, err := NewBucketNamer(tc.strategy, tc.template)
+			if err == nil {
+				var name string
+				name, err = namer.BucketName(bucketClaim, "classgold")
+				if err == nil && name != tc.expectedName {
+					t.Errorf("expected name %q got %q", tc.expectedName, name)
+				}
+			}
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+// Test the bucket naming rules of each protocol
+func TestCheckProtocolBucketName(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		bucketName  string
+		protocols   []v1alpha1.Protocol
+		expectedErr error
+	}{
+		{
+			name:       "ValidForAll",
+			bucketName: "classgold-12345-67890",
+			protocols:  []v1alpha1.Protocol{v1alpha1.ProtocolS3, v1alpha1.ProtocolAzure, v1alpha1.ProtocolGCP},
+		},
+		{
+			name:        "S3TooLong",
+			bucketName:  strings.Repeat("a", 64),
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+		{
+			name:        "S3IPAddress",
+			bucketName:  "192.168.1.1",
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+		{
+			name:       "S3Dots",
+			bucketName: "bucket.example.com",
+			protocols:  []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+		},
+		{
+			name:        "AzureDots",
+			bucketName:  "bucket.example.com",
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolAzure},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+		{
+			name:        "AzureAdjacentDashes",
+			bucketName:  "bucket--1",
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolAzure},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+		{
+			name:       "GCSLongDotted",
+			bucketName: strings.Repeat("a", 63) + "." + strings.Repeat("b", 63),
+			protocols:  []v1alpha1.Protocol{v1alpha1.ProtocolGCP},
+		},
+		{
+			name:        "GCSLongComponent",
+			bucketName:  strings.Repeat("a", 64) + ".b",
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolGCP},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+		{
+			name:        "GCSGoogle",
+			bucketName:  "my-g00gle-bucket",
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolGCP},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+		{
+			name:        "AnyProtocolFails",
+			bucketName:  "bucket.example.com",
+			protocols:   []v1alpha1.Protocol{v1alpha1.ProtocolS3, v1alpha1.ProtocolAzure},
+			expectedErr: util.ErrBucketNameNotCompliant,
+		},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkProtocolBucketName(tc.bucketName, tc.protocols)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+// Test replacing generated bucket names that break protocol naming rules
+func TestCompliantBucketName(t *testing.T) {
+	t.Parallel()
+
+	bucketClaim := bucketClaim1.DeepCopy()
+	longClassName := strings.Repeat("a", 60)
+
+	eventRecorder := record.NewFakeRecorder(10)
+	listener := NewBucketClaimListener()
+	listener.InitializeEventRecorder(eventRecorder)
+
+	name, err := concatNamer{}.BucketName(bucketClaim, longClassName)
+	if err != nil {
+		t.Fatalf("Error occurred when generating bucket name: %v", err)
+	}
+	name, err = listener.compliantBucketName(bucketClaim, longClassName, concatNamer{}, name)
+	if err != nil {
+		t.Fatalf("Error occurred when checking bucket name: %v", err)
+	}
+	expected, _ := hashedNamer{}.BucketName(bucketClaim, longClassName)
+	if name != expected {
+		t.Errorf("expected fallback name %q got %q", expected, name)
+	}
+	select {
+	case event := <-eventRecorder.Events:
+		if !strings.Contains(event, util.BucketNameFallback) {
+			t.Errorf("expected %s event got %q", util.BucketNameFallback, event)
+		}
+	default:
+		t.Errorf("expected an event about the replaced bucket name")
+	}
+
+	// Names rendered from a template are not replaced
+	namer, err := NewBucketNamer(NamingStrategyTemplate, "{{.Class}}-{{.UID}}")
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketNamer: %v", err)
+	}
+	name, err = namer.BucketName(bucketClaim, longClassName)
+	if err != nil {
+		t.Fatalf("Error occurred when generating bucket name: %v", err)
+	}
+	if _, err := listener.compliantBucketName(bucketClaim, longClassName, namer, name); !errors.Is(err, util.ErrBucketNameNotCompliant) {
+		t.Errorf("expected error %v got %v", util.ErrBucketNameNotCompliant, err)
+	}
+}
@@ -0,0 +1,179 @@
+package bucketclaim
+
+import (
+	"context"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// patchBucketClaim applies mutate to a copy of bucketClaim and writes the
+// changes as a merge patch, leaving the fields written by others alone.
+//
+// Merge patches replace lists as a whole, so a patch of the finalizers only
+// applies to the resourceVersion mutate saw. On conflicts, mutate is applied
+// again to the latest version of the bucketClaim.
+func (b *BucketClaimListener) patchBucketClaim(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, mutate func(*v1alpha1.BucketClaim) error) (*v1alpha1.BucketClaim, error) {
+	return b.writeBucketClaimPatch(ctx, bucketClaim, mutate)
+}
+
+// patchBucketClaimStatus is patchBucketClaim for the status of bucketClaim
+func (b *BucketClaimListener) patchBucketClaimStatus(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, mutate func(*v1alpha1.BucketClaim) error) (*v1alpha1.BucketClaim, error) {
+	return b.writeBucketClaimPatch(ctx, bucketClaim, mutate, "status")
+}
+
+func (b *BucketClaimListener) writeBucketClaimPatch(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, mutate func(*v1alpha1.BucketClaim) error, subresources ...string) (*v1alpha1.BucketClaim, error) {
+	current := bucketClaim
+	var patched *v1alpha1.BucketClaim
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		modified := current.DeepCopy()
+		err := mutate(modified)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion := ""
+		if !equality.Semantic.DeepEqual(current.ObjectMeta.Finalizers, modified.ObjectMeta.Finalizers) {
+			resourceVersion = current.ObjectMeta.ResourceVersion
+		}
+		patch, err := mergePatch(current, modified, resourceVersion)
+		if err != nil || patch == nil {
+			patched = current
+			return err
+		}
+
+		namespace, name := current.ObjectMeta.Namespace, current.ObjectMeta.Name
+		patched, err = b.bucketClaims(namespace).Patch(ctx, name, types.MergePatchType, patch,
+			metav1.PatchOptions{FieldManager: util.FieldManager}, subresources...)
+		if kubeerrors.IsConflict(err) {
+			latest, getErr := b.bucketClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			current = latest
+		}
+		return err
+	})
+	return patched, err
+}
+
+// patchBucket applies mutate to a copy of bucket and writes the changes as a
+// merge patch. Patches of the finalizers or of the bucketClaim the bucket is
+// bound to only apply to the resourceVersion mutate saw. On conflicts, mutate
+// is applied again to the latest version of the bucket.
+func (b *BucketClaimListener) patchBucket(ctx context.Context, bucket *v1alpha1.Bucket, mutate func(*v1alpha1.Bucket) error) (*v1alpha1.Bucket, error) {
+	current := bucket
+	var patched *v1alpha1.Bucket
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		modified := current.DeepCopy()
+		err := mutate(modified)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion := ""
+		if !equality.Semantic.DeepEqual(current.ObjectMeta.Finalizers, modified.ObjectMeta.Finalizers) ||
+			!equality.Semantic.DeepEqual(current.Spec.BucketClaim, modified.Spec.BucketClaim) {
+			resourceVersion = current.ObjectMeta.ResourceVersion
+		}
+		patch, err := mergePatch(current, modified, resourceVersion)
+		if err != nil || patch == nil {
+			patched = current
+			return err
+		}
+
+		patched, err = b.buckets().Patch(ctx, current.ObjectMeta.Name, types.MergePatchType, patch,
+			metav1.PatchOptions{FieldManager: util.FieldManager})
+		if kubeerrors.IsConflict(err) {
+			latest, getErr := b.buckets().Get(ctx, current.ObjectMeta.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			current = latest
+		}
+		return err
+	})
+	return patched, err
+}
+
+// patchBucketClass applies mutate to a copy of bucketClass and writes the
+// changes as a merge patch. Patches of the finalizers only apply to the
+// resourceVersion mutate saw. On conflicts, mutate is applied again to the
+// latest version of the bucketClass.
+func (b *BucketClaimListener) patchBucketClass(ctx context.Context, bucketClass *v1alpha1.BucketClass, mutate func(*v1alpha1.BucketClass) error) (*v1alpha1.BucketClass, error) {
+	current := bucketClass
+	var patched *v1alpha1.BucketClass
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		modified := current.DeepCopy()
+		err := mutate(modified)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion := ""
+		if !equality.Semantic.DeepEqual(current.ObjectMeta.Finalizers, modified.ObjectMeta.Finalizers) {
+			resourceVersion = current.ObjectMeta.ResourceVersion
+		}
+		patch, err := mergePatch(current, modified, resourceVersion)
+		if err != nil || patch == nil {
+			patched = current
+			return err
+		}
+
+		patched, err = b.bucketClasses().Patch(ctx, current.ObjectMeta.Name, types.MergePatchType, patch,
+			metav1.PatchOptions{FieldManager: util.FieldManager})
+		if kubeerrors.IsConflict(err) {
+			latest, getErr := b.bucketClasses().Get(ctx, current.ObjectMeta.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			current = latest
+		}
+		return err
+	})
+	return patched, err
+}
+
+// mergePatch returns the merge patch from original to modified, or nil if
+// they do not differ. A non-empty resourceVersion makes the patch conditional
+// on it.
+func mergePatch(original, modified interface{}, resourceVersion string) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	patchJSON, err := jsonpatch.CreateMergePatch(originalJSON, modifiedJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(patchJSON, &patch); err != nil {
+		return nil, err
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+
+	if resourceVersion != "" {
+		metadata, _ := patch["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+			patch["metadata"] = metadata
+		}
+		metadata["resourceVersion"] = resourceVersion
+	}
+	return json.Marshal(patch)
+}
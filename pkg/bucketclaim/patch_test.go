@@ -0,0 +1,50 @@
+package bucketclaim
+
+import (
+	"testing"
+
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test that merge patches only carry the changed fields
+func TestMergePatch(t *testing.T) {
+	original := bucketClaim1.DeepCopy()
+	original.ResourceVersion = "7"
+
+	withStatus := original.DeepCopy()
+	withStatus.Status.BucketName = "bucket1"
+
+	withFinalizer := original.DeepCopy()
+	withFinalizer.Finalizers = []string{util.BucketClaimFinalizer}
+
+	for _, tc := range []struct {
+		name            string
+		modified        interface{}
+		resourceVersion string
+		expected        string
+	}{
+		{
+			name:     "Unchanged",
+			modified: original.DeepCopy(),
+		},
+		{
+			name:     "Status",
+			modified: withStatus,
+			expected: `{"status":{"bucketName":"bucket1"}}`,
+		},
+		{
+			name:            "Preconditioned",
+			modified:        withFinalizer,
+			resourceVersion: "7",
+			expected:        `{"metadata":{"finalizers":["` + util.BucketClaimFinalizer + `"],"resourceVersion":"7"}}`,
+		},
+	} {
+		patch, err := mergePatch(original, tc.modified, tc.resourceVersion)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if string(patch) != tc.expected {
+			t.Errorf("%s: expected patch %s got %s", tc.name, tc.expected, patch)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package bucketclaim
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+var knownProtocols = map[v1alpha1.Protocol]bool{
+	v1alpha1.ProtocolS3:    true,
+	v1alpha1.ProtocolAzure: true,
+	v1alpha1.ProtocolGCP:   true,
+}
+
+// validateClaimProtocols checks that a bucketClaim requests at least one protocol
+// and that every requested protocol is known
+func validateClaimProtocols(bucketClaim *v1alpha1.BucketClaim) error {
+	if len(bucketClaim.Spec.Protocols) == 0 {
+		return util.ErrNoProtocols
+	}
+	for _, protocol := range bucketClaim.Spec.Protocols {
+		if !knownProtocols[protocol] {
+			return fmt.Errorf("%w: %q", util.ErrUnknownProtocol, protocol)
+		}
+	}
+	return nil
+}
+
+// allowedProtocols returns the protocols a bucketClass declares through its
+// reserved parameter, or nil if the bucketClass does not restrict protocols
+func allowedProtocols(bucketClass *v1alpha1.BucketClass) ([]v1alpha1.Protocol, error) {
+	return protocolsParameter(bucketClass, util.ProtocolsParameter)
+}
+
+// defaultProtocols returns the protocols a bucketClass declares as the
+// default of its bucketClaims, or nil if it declares none. Default protocols
+// must be allowed by the bucketClass.
+func defaultProtocols(bucketClass *v1alpha1.BucketClass) ([]v1alpha1.Protocol, error) {
+	allowed, err := allowedProtocols(bucketClass)
+	if err != nil {
+		return nil, err
+	}
+	protocols, err := protocolsParameter(bucketClass, util.DefaultProtocolsParameter)
+	if err != nil || protocols == nil || allowed == nil {
+		return protocols, err
+	}
+	for _, protocol := range protocols {
+		if !containsProtocol(allowed, protocol) {
+			return nil, fmt.Errorf("%w: default protocol %q is not allowed by bucket class %q, allowed protocols are %v",
+				util.ErrProtocolMismatch, protocol, bucketClass.ObjectMeta.Name, allowed)
+		}
+	}
+	return protocols, nil
+}
+
+// protocolsParameter parses the comma separated protocols of a reserved
+// bucketClass parameter, returning nil if the parameter is not set
+func protocolsParameter(bucketClass *v1alpha1.BucketClass, parameter string) ([]v1alpha1.Protocol, error) {
+	value, ok := bucketClass.Parameters[parameter]
+	if !ok {
+		return nil, nil
+	}
+
+	protocols := []v1alpha1.Protocol{}
+	for _, p := range strings.Split(value, ",") {
+		protocol := v1alpha1.Protocol(strings.TrimSpace(p))
+		if !knownProtocols[protocol] {
+			return nil, fmt.Errorf("%w: %q in bucket class %q parameter %s",
+				util.ErrUnknownProtocol, protocol, bucketClass.ObjectMeta.Name, parameter)
+		}
+		protocols = append(protocols, protocol)
+	}
+	return protocols, nil
+}
+
+// checkClassProtocols verifies that every protocol requested by bucketClaim
+// is allowed by bucketClass
+func checkClassProtocols(bucketClaim *v1alpha1.BucketClaim, bucketClass *v1alpha1.BucketClass) error {
+	allowed, err := allowedProtocols(bucketClass)
+	if err != nil || allowed == nil {
+		return err
+	}
+
+	for _, protocol := range bucketClaim.Spec.Protocols {
+		if !containsProtocol(allowed, protocol) {
+			return fmt.Errorf("%w: %q is not allowed by bucket class %q, allowed protocols are %v",
+				util.ErrProtocolMismatch, protocol, bucketClass.ObjectMeta.Name, allowed)
+		}
+	}
+	return nil
+}
+
+// checkBucketProtocols verifies that an existing bucket serves at least one of
+// the protocols requested by bucketClaim. Buckets that do not declare any
+// protocol are accepted.
+func checkBucketProtocols(bucketClaim *v1alpha1.BucketClaim, bucket *v1alpha1.Bucket) error {
+	if len(bucket.Spec.Protocols) == 0 {
+		return nil
+	}
+
+	for _, protocol := range bucketClaim.Spec.Protocols {
+		if containsProtocol(bucket.Spec.Protocols, protocol) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: bucket %q supports %v but bucket claim requests %v",
+		util.ErrProtocolMismatch, bucket.ObjectMeta.Name, bucket.Spec.Protocols, bucketClaim.Spec.Protocols)
+}
+
+// protocolsChanged reports whether two lists of protocols differ, ignoring
+// their order
+func protocolsChanged(old, new []v1alpha1.Protocol) bool {
+	for _, protocol := range old {
+		if !containsProtocol(new, protocol) {
+			return true
+		}
+	}
+	for _, protocol := range new {
+		if !containsProtocol(old, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsProtocol(protocols []v1alpha1.Protocol, protocol v1alpha1.Protocol) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// updateBucketProtocolsOperation pushes the protocols of a bound bucketClaim
+// to its bucket. Protocols are only added when the bucketClass allows them,
+// and only removed when no bucketAccess of the bucketClaim uses them. The
+// protocols of existing buckets describe what the backend serves and are never
+// changed.
+//
+// Return values
+//   - nil - Bucket protocols updated, or the edit cannot be applied and an event was recorded
+//   - non-nil err - Internal error                              [requeue'd with exponential backoff]
+func (b *BucketClaimListener) updateBucketProtocolsOperation(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) error {
+	bucketName := bucketClaim.Status.BucketName
+	reject := func(err error) error {
+		klog.V(3).ErrorS(err, "BucketClaim protocols cannot be applied to its bucket",
+			"bucket", bucketName,
+			"bucketClaim", bucketClaim.ObjectMeta.Name,
+			"ns", bucketClaim.ObjectMeta.Namespace)
+		b.recordEvent(bucketClaim, v1.EventTypeWarning, util.FailedUpdateBucketProtocols,
+			"Protocols %v not applied to bucket %q: %v", bucketClaim.Spec.Protocols, bucketName, err)
+		return nil
+	}
+
+	err := validateClaimProtocols(bucketClaim)
+	if err != nil {
+		return reject(err)
+	}
+
+	bucket, err := b.getBucket(ctx, bucketName)
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		klog.V(3).ErrorS(err, "Get Bucket error", "name", bucketName)
+		return err
+	}
+	if bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != bucketClaim.ObjectMeta.UID ||
+		!protocolsChanged(bucket.Spec.Protocols, bucketClaim.Spec.Protocols) {
+		return nil
+	}
+
+	internal, err := b.checkProtocolsUpdate(ctx, bucketClaim, bucket)
+	if internal {
+		return err
+	} else if err != nil {
+		return reject(err)
+	}
+	if bucketClaim.Spec.ExistingBucketName != "" {
+		// Only narrowed to protocols the bucket serves, left as is
+		return nil
+	}
+
+	_, err = b.patchBucket(ctx, bucket, func(bucket *v1alpha1.Bucket) error {
+		protocolCopy := make([]v1alpha1.Protocol, len(bucketClaim.Spec.Protocols))
+		copy(protocolCopy, bucketClaim.Spec.Protocols)
+
+		bucket.Spec.Protocols = protocolCopy
+		return nil
+	})
+	if err != nil {
+		klog.V(3).ErrorS(err, "Error updating bucket protocols",
+			"bucket", bucketName,
+			"bucketClaim", bucketClaim.ObjectMeta.Name)
+		return b.recordError(bucketClaim, v1.EventTypeWarning, util.FailedUpdateBucketProtocols, err)
+	}
+
+	b.recordEvent(bucketClaim, v1.EventTypeNormal, util.BucketProtocolsUpdated,
+		"Bucket %q protocols updated to %v", bucketName, bucketClaim.Spec.Protocols)
+	return nil
+}
+
+// checkProtocolsUpdate verifies that the protocols of bucketClaim can be
+// pushed to the bucket it is bound to. Protocols must be served by an
+// existing bucket or allowed by the bucketClass of the bucket, and protocols
+// used by a bucketAccess of the bucketClaim cannot be removed. internal
+// reports whether err is a failure to read the objects involved rather than a
+// rejection of the protocols.
+func (b *BucketClaimListener) checkProtocolsUpdate(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, bucket *v1alpha1.Bucket) (internal bool, err error) {
+	if bucketClaim.Spec.ExistingBucketName != "" {
+		for _, protocol := range bucketClaim.Spec.Protocols {
+			if !containsProtocol(bucket.Spec.Protocols, protocol) {
+				return false, fmt.Errorf("%w: bucket serves %v", util.ErrExistingBucketProtocols, bucket.Spec.Protocols)
+			}
+		}
+		return false, nil
+	}
+
+	bucketClass, err := b.getBucketClass(ctx, bucket.Spec.BucketClassName)
+	if kubeerrors.IsNotFound(err) {
+		return false, err
+	} else if err != nil {
+		klog.V(3).ErrorS(err, "Get Bucketclass Error", "name", bucket.Spec.BucketClassName)
+		return true, err
+	}
+	err = checkClassProtocols(bucketClaim, bucketClass)
+	if err != nil {
+		return false, err
+	}
+
+	removed := []v1alpha1.Protocol{}
+	for _, protocol := range bucket.Spec.Protocols {
+		if !containsProtocol(bucketClaim.Spec.Protocols, protocol) {
+			removed = append(removed, protocol)
+		}
+	}
+	if len(removed) == 0 {
+		return false, nil
+	}
+	bucketAccesses, err := b.bucketAccessesUsing(ctx, bucketClaim, removed)
+	if err != nil {
+		return true, err
+	}
+	if len(bucketAccesses) > 0 {
+		return false, fmt.Errorf("%w: %v used by %s", util.ErrProtocolInUse, removed, util.JoinNames(bucketAccesses))
+	}
+	return false, nil
+}
+
+// bucketAccessesUsing returns the names of the bucketAccesses of bucketClaim
+// that use one of protocols
+func (b *BucketClaimListener) bucketAccessesUsing(ctx context.Context, bucketClaim *v1alpha1.BucketClaim, protocols []v1alpha1.Protocol) ([]string, error) {
+	list, err := b.bucketAccesses(bucketClaim.ObjectMeta.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, bucketAccess := range list.Items {
+		if bucketAccess.Spec.BucketClaimName == bucketClaim.ObjectMeta.Name &&
+			bucketAccess.GetDeletionTimestamp().IsZero() &&
+			containsProtocol(protocols, bucketAccess.Spec.Protocol) {
+			names = append(names, bucketAccess.ObjectMeta.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,201 @@
+package bucketclaim
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// driverNameRegexp matches the driver names allowed by the COSI spec: at most
+// 63 alphanumerics, dashes and dots, starting and ending with an alphanumeric
+var driverNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9.]{0,61}[a-zA-Z0-9])?$`)
+
+// ValidateBucketClaim runs the checks provisionBucketClaimOperation makes
+// before it provisions or binds a bucket for bucketClaim, without writing
+// anything. An admission webhook can use it to reject invalid bucketClaims.
+func (b *BucketClaimListener) ValidateBucketClaim(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) error {
+	err := validateClaimProtocols(bucketClaim)
+	if err != nil {
+		return err
+	}
+
+	if bucketClaim.Spec.ExistingBucketName != "" {
+		bucket, err := b.getBucket(ctx, bucketClaim.Spec.ExistingBucketName)
+		if err != nil {
+			return err
+		}
+		err = checkBucketBinding(bucket, bucketClaim)
+		if err != nil {
+			return err
+		}
+		return checkBucketProtocols(bucketClaim, bucket)
+	}
+
+	bucketClassName, err := b.resolveBucketClassName(ctx, bucketClaim)
+	if err != nil {
+		return err
+	}
+	bucketClass, err := b.getBucketClass(ctx, bucketClassName)
+	if err != nil {
+		return err
+	}
+	err = checkClassProtocols(bucketClaim, bucketClass)
+	if err != nil {
+		return err
+	}
+	_, err = b.bucketNamerFor(bucketClass)
+	return err
+}
+
+// ValidateBucketClass checks the driver name, the deletion policy and the
+// parameters reserved for the central controller of bucketClass. Default
+// protocols must be allowed by the bucketClass.
+func ValidateBucketClass(bucketClass *v1alpha1.BucketClass) error {
+	if !driverNameRegexp.MatchString(bucketClass.DriverName) {
+		return fmt.Errorf("%w: %q", util.ErrInvalidDriverName, bucketClass.DriverName)
+	}
+
+	switch bucketClass.DeletionPolicy {
+	case v1alpha1.DeletionPolicyRetain, v1alpha1.DeletionPolicyDelete:
+	default:
+		return fmt.Errorf("%w: %q, must be one of %s or %s", util.ErrInvalidDeletionPolicy,
+			bucketClass.DeletionPolicy, v1alpha1.DeletionPolicyRetain, v1alpha1.DeletionPolicyDelete)
+	}
+
+	_, err := defaultProtocols(bucketClass)
+	if err != nil {
+		return err
+	}
+	if strategy, ok := bucketClass.Parameters[util.BucketNamingStrategyParameter]; ok {
+		_, err = NewBucketNamer(strategy, bucketClass.Parameters[util.BucketNameTemplateParameter])
+	}
+	return err
+}
+
+// ValidateBucketClaimUpdate returns an error if new changes a field of old
+// that cannot change once old is bound to a bucket. The controller reverts
+// such changes, an admission webhook can use it to reject them.
+func ValidateBucketClaimUpdate(old, new *v1alpha1.BucketClaim) error {
+	if old.Status.BucketName == "" {
+		return nil
+	}
+
+	changed := []string{}
+	if old.Spec.BucketClassName != new.Spec.BucketClassName {
+		changed = append(changed, "spec.bucketClassName")
+	}
+	if old.Spec.ExistingBucketName != new.Spec.ExistingBucketName {
+		changed = append(changed, "spec.existingBucketName")
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s cannot change once the bucket claim is bound to bucket %q",
+		util.ErrBucketClaimSpecImmutable, strings.Join(changed, ", "), old.Status.BucketName)
+}
+
+// ValidateBoundBucketClaimUpdate runs the checks updateBucketProtocolsOperation
+// makes before it pushes the protocols of bound bucketClaim to its bucket,
+// without writing anything. An admission webhook can use it to reject protocol
+// edits the controller would not apply.
+func (b *BucketClaimListener) ValidateBoundBucketClaimUpdate(ctx context.Context, bucketClaim *v1alpha1.BucketClaim) error {
+	err := validateClaimProtocols(bucketClaim)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := b.getBucket(ctx, bucketClaim.Status.BucketName)
+	if kubeerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if bucket.Spec.BucketClaim == nil || bucket.Spec.BucketClaim.UID != bucketClaim.ObjectMeta.UID ||
+		!protocolsChanged(bucket.Spec.Protocols, bucketClaim.Spec.Protocols) {
+		return nil
+	}
+
+	_, err = b.checkProtocolsUpdate(ctx, bucketClaim, bucket)
+	return err
+}
+
+// revertBucketClaimSpec restores the fields of a bound bucketClaim that were
+// changed although ValidateBucketClaimUpdate forbids it, and records err as a
+// warning on the bucketClaim.
+//
+// The revert itself is an update that ValidateBucketClaimUpdate rejects, so
+// fields are only reverted when they do not match the bucket the bucketClaim
+// is bound to, or when they were cleared, and only to values that match it.
+// Setting a cleared field to the value matching the bucket is kept, that is
+// how the revert of a cleared field shows up.
+func (b *BucketClaimListener) revertBucketClaimSpec(ctx context.Context, old, bucketClaim *v1alpha1.BucketClaim, err error) error {
+	bucket, getErr := b.getBucket(ctx, bucketClaim.Status.BucketName)
+	if kubeerrors.IsNotFound(getErr) {
+		b.recordEvent(bucketClaim, v1.EventTypeWarning, util.BucketClaimSpecImmutable, "%v, the change was not reverted as the bucket was not found", err)
+		return nil
+	} else if getErr != nil {
+		klog.V(3).ErrorS(getErr, "Get Bucket error", "name", bucketClaim.Status.BucketName)
+		return getErr
+	}
+
+	bucketClassName := revertTarget(old.Spec.BucketClassName, bucketClaim.Spec.BucketClassName,
+		func(name string) bool { return bucketClassMatches(name, bucket) }, bucket.Spec.BucketClassName)
+	existingBucketName := revertTarget(old.Spec.ExistingBucketName, bucketClaim.Spec.ExistingBucketName,
+		func(name string) bool { return existingBucketMatches(name, bucket) }, "")
+	if bucketClassName == bucketClaim.Spec.BucketClassName && existingBucketName == bucketClaim.Spec.ExistingBucketName {
+		return nil
+	}
+
+	klog.V(3).ErrorS(err, "Reverting BucketClaim spec",
+		"name", bucketClaim.ObjectMeta.Name,
+		"ns", bucketClaim.ObjectMeta.Namespace)
+
+	_, patchErr := b.patchBucketClaim(ctx, bucketClaim, func(bucketClaim *v1alpha1.BucketClaim) error {
+		bucketClaim.Spec.BucketClassName = bucketClassName
+		bucketClaim.Spec.ExistingBucketName = existingBucketName
+		return nil
+	})
+	if patchErr != nil {
+		klog.V(3).ErrorS(patchErr, "Failed to revert BucketClaim spec",
+			"name", bucketClaim.ObjectMeta.Name,
+			"ns", bucketClaim.ObjectMeta.Namespace)
+		return patchErr
+	}
+
+	b.recordEvent(bucketClaim, v1.EventTypeWarning, util.BucketClaimSpecImmutable, "%v, the change was reverted", err)
+	return nil
+}
+
+// revertTarget returns the value an immutable field of a bound bucketClaim
+// is reverted to from new: new if it is set and matches the bucket, old if it
+// matches the bucket, fallback otherwise
+func revertTarget(old, new string, matches func(string) bool, fallback string) string {
+	switch {
+	case new != "" && matches(new):
+		return new
+	case matches(old):
+		return old
+	default:
+		return fallback
+	}
+}
+
+// bucketClassMatches reports whether a bucketClaim naming bucketClassName
+// can be bound to bucket. BucketClaims using the default bucketClass name none.
+func bucketClassMatches(bucketClassName string, bucket *v1alpha1.Bucket) bool {
+	return bucketClassName == "" || bucketClassName == bucket.Spec.BucketClassName
+}
+
+// existingBucketMatches reports whether a bucketClaim naming
+// existingBucketName can be bound to bucket
+func existingBucketMatches(existingBucketName string, bucket *v1alpha1.Bucket) bool {
+	return existingBucketName == "" || existingBucketName == bucket.ObjectMeta.Name
+}
@@ -0,0 +1,276 @@
+package bucketclaim
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test the fields of bound bucketClaims that cannot change
+func TestValidateBucketClaimUpdate(t *testing.T) {
+	t.Parallel()
+
+	bound := bucketClaim1.DeepCopy()
+	bound.Status.BucketName = "bucket1"
+
+	for _, tc := range []struct {
+		name          string
+		old           *v1alpha1.BucketClaim
+		mutate        func(*v1alpha1.BucketClaim)
+		expectedError error
+	}{
+		{
+			name:   "UnboundClassChange",
+			old:    bucketClaim1.DeepCopy(),
+			mutate: func(b *v1alpha1.BucketClaim) { b.Spec.BucketClassName = "classsilver" },
+		},
+		{
+			name:   "BoundProtocolsChange",
+			old:    bound,
+			mutate: func(b *v1alpha1.BucketClaim) { b.Spec.Protocols = []v1alpha1.Protocol{v1alpha1.ProtocolS3} },
+		},
+		{
+			name:          "BoundClassChange",
+			old:           bound,
+			mutate:        func(b *v1alpha1.BucketClaim) { b.Spec.BucketClassName = "classsilver" },
+			expectedError: util.ErrBucketClaimSpecImmutable,
+		},
+		{
+			name:          "BoundExistingBucketChange",
+			old:           bound,
+			mutate:        func(b *v1alpha1.BucketClaim) { b.Spec.ExistingBucketName = "bucket2" },
+			expectedError: util.ErrBucketClaimSpecImmutable,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			new := tc.old.DeepCopy()
+			tc.mutate(new)
+			err := ValidateBucketClaimUpdate(tc.old, new)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("expected %v got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+// Test reverting changes to the immutable fields of a bound bucketClaim
+func TestRevertBucketClaimSpec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fakebucketclientset.NewSimpleClientset(goldClass.DeepCopy())
+	eventRecorder := record.NewFakeRecorder(10)
+	listener := NewBucketClaimListener()
+	listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	listener.InitializeBucketClient(client)
+	listener.InitializeEventRecorder(eventRecorder)
+
+	bucketClaim, err := util.CreateBucketClaim(ctx, client, &bucketClaim1)
+	if err != nil {
+		t.Fatalf("Error occurred when creating BucketClaim: %v", err)
+	}
+	if err := listener.Add(ctx, bucketClaim); err != nil {
+		t.Fatalf("Error occurred when provisioning BucketClaim: %v", err)
+	}
+	old, err := client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	for len(eventRecorder.Events) > 0 {
+		<-eventRecorder.Events
+	}
+
+	changed := old.DeepCopy()
+	changed.Spec.BucketClassName = "classsilver"
+	changed, err = client.ObjectstorageV1alpha1().BucketClaims(changed.Namespace).Update(ctx, changed, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when updating BucketClaim: %v", err)
+	}
+	if err := listener.Update(ctx, old, changed); err != nil {
+		t.Fatalf("Error occurred when processing BucketClaim update: %v", err)
+	}
+
+	reverted, err := client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if reverted.Spec.BucketClassName != old.Spec.BucketClassName {
+		t.Errorf("expected BucketClassName reverted to %q got %q", old.Spec.BucketClassName, reverted.Spec.BucketClassName)
+	}
+
+	select {
+	case event := <-eventRecorder.Events:
+		if !strings.Contains(event, util.BucketClaimSpecImmutable) || !strings.Contains(event, "spec.bucketClassName") {
+			t.Errorf("expected event about the reverted field got %q", event)
+		}
+	default:
+		t.Errorf("expected a warning event about the reverted change")
+	}
+
+	// The update caused by the revert is left alone
+	if err := listener.Update(ctx, changed, reverted); err != nil {
+		t.Fatalf("Error occurred when processing BucketClaim update: %v", err)
+	}
+	again, err := client.ObjectstorageV1alpha1().BucketClaims(bucketClaim.Namespace).Get(ctx, bucketClaim.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+	}
+	if again.Spec.BucketClassName != old.Spec.BucketClassName || len(eventRecorder.Events) != 0 {
+		t.Errorf("expected the reverted BucketClaim to be left alone got %q", again.Spec.BucketClassName)
+	}
+}
+
+// Test validation of bucketClasses
+func TestValidateBucketClass(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name          string
+		mutate        func(*v1alpha1.BucketClass)
+		expectedError error
+	}{
+		{
+			name:   "Valid",
+			mutate: func(b *v1alpha1.BucketClass) {},
+		},
+		{
+			name:          "DriverNameTooLong",
+			mutate:        func(b *v1alpha1.BucketClass) { b.DriverName = strings.Repeat("a", 64) },
+			expectedError: util.ErrInvalidDriverName,
+		},
+		{
+			name:          "DriverNameInvalidCharacter",
+			mutate:        func(b *v1alpha1.BucketClass) { b.DriverName = "sample/driver" },
+			expectedError: util.ErrInvalidDriverName,
+		},
+		{
+			name:          "UnknownDeletionPolicy",
+			mutate:        func(b *v1alpha1.BucketClass) { b.DeletionPolicy = "Keep" },
+			expectedError: util.ErrInvalidDeletionPolicy,
+		},
+		{
+			name:          "UnknownProtocol",
+			mutate:        func(b *v1alpha1.BucketClass) { b.Parameters = map[string]string{util.ProtocolsParameter: "S3,NFS"} },
+			expectedError: util.ErrUnknownProtocol,
+		},
+		{
+			name: "DefaultProtocolNotAllowed",
+			mutate: func(b *v1alpha1.BucketClass) {
+				b.Parameters = map[string]string{
+					util.ProtocolsParameter:        "S3",
+					util.DefaultProtocolsParameter: "Azure",
+				}
+			},
+			expectedError: util.ErrProtocolMismatch,
+		},
+		{
+			name: "UnknownNamingStrategy",
+			mutate: func(b *v1alpha1.BucketClass) {
+				b.Parameters = map[string]string{util.BucketNamingStrategyParameter: "random"}
+			},
+			expectedError: util.ErrInvalidBucketNamingStrategy,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bucketClass := goldClass.DeepCopy()
+			tc.mutate(bucketClass)
+			err := ValidateBucketClass(bucketClass)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("expected %v got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+// Test reverting the immutable fields of a bound bucketClaim once cleared
+func TestRevertClearedBucketClaimSpec(t *testing.T) {
+	t.Parallel()
+
+	bucket := &v1alpha1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket1"},
+		Spec:       v1alpha1.BucketSpec{BucketClassName: goldClass.Name},
+	}
+	bound := bucketClaim1.DeepCopy()
+	bound.Status.BucketName = bucket.Name
+
+	existing := bound.DeepCopy()
+	existing.Spec.BucketClassName = ""
+	existing.Spec.ExistingBucketName = bucket.Name
+
+	for _, tc := range []struct {
+		name   string
+		old    *v1alpha1.BucketClaim
+		mutate func(*v1alpha1.BucketClaim)
+		field  string
+	}{
+		{
+			name:   "BucketClassName",
+			old:    bound,
+			mutate: func(b *v1alpha1.BucketClaim) { b.Spec.BucketClassName = "" },
+			field:  "spec.bucketClassName",
+		},
+		{
+			name:   "ExistingBucketName",
+			old:    existing,
+			mutate: func(b *v1alpha1.BucketClaim) { b.Spec.ExistingBucketName = "" },
+			field:  "spec.existingBucketName",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.TODO()
+
+			changed := tc.old.DeepCopy()
+			tc.mutate(changed)
+			client := fakebucketclientset.NewSimpleClientset(bucket.DeepCopy(), changed.DeepCopy())
+			eventRecorder := record.NewFakeRecorder(10)
+			listener := NewBucketClaimListener()
+			listener.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+			listener.InitializeBucketClient(client)
+			listener.InitializeEventRecorder(eventRecorder)
+
+			if err := listener.Update(ctx, tc.old, changed); err != nil {
+				t.Fatalf("Error occurred when processing BucketClaim update: %v", err)
+			}
+
+			reverted, err := client.ObjectstorageV1alpha1().BucketClaims(changed.Namespace).Get(ctx, changed.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Error occurred when reading BucketClaim: %v", err)
+			}
+			if reverted.Spec.BucketClassName != tc.old.Spec.BucketClassName || reverted.Spec.ExistingBucketName != tc.old.Spec.ExistingBucketName {
+				t.Errorf("expected spec reverted to %+v got %+v", tc.old.Spec, reverted.Spec)
+			}
+			select {
+			case event := <-eventRecorder.Events:
+				if !strings.Contains(event, util.BucketClaimSpecImmutable) || !strings.Contains(event, tc.field) {
+					t.Errorf("expected event about the reverted field got %q", event)
+				}
+			default:
+				t.Errorf("expected a warning event about the reverted change")
+			}
+
+			// The update caused by the revert is left alone
+			if err := listener.Update(ctx, changed, reverted); err != nil {
+				t.Fatalf("Error occurred when processing BucketClaim update: %v", err)
+			}
+			if len(eventRecorder.Events) != 0 {
+				t.Errorf("expected the reverted BucketClaim to be left alone got %q", <-eventRecorder.Events)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// Check returns nil when the checked component is in a good state
+type Check func() error
+
+// Checker aggregates the liveness and readiness checks of the controller and
+// serves them on /healthz and /readyz
+type Checker struct {
+	lock         sync.RWMutex
+	healthChecks map[string]Check
+	readyChecks  map[string]Check
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		healthChecks: map[string]Check{},
+		readyChecks:  map[string]Check{},
+	}
+}
+
+// AddHealthCheck adds a check to the /healthz endpoint
+func (c *Checker) AddHealthCheck(name string, check Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.healthChecks[name] = check
+}
+
+// AddReadyCheck adds a check to the /readyz endpoint
+func (c *Checker) AddReadyCheck(name string, check Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.readyChecks[name] = check
+}
+
+// Handler returns the HTTP handler serving /healthz and /readyz
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		c.serve(w, c.healthChecks)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		c.serve(w, c.readyChecks)
+	})
+	return mux
+}
+
+// serve runs checks and writes one line per check, failing the request if
+// any check fails
+func (c *Checker) serve(w http.ResponseWriter, checks map[string]Check) {
+	c.lock.RLock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	failed := false
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			failed = true
+			fmt.Fprintf(&out, "[-]%s failed: %v\n", name, err)
+		} else {
+			fmt.Fprintf(&out, "[+]%s ok\n", name)
+		}
+	}
+	c.lock.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if failed {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	fmt.Fprint(w, out.String())
+}
+
+// InformersSynced returns a Check that passes once every informer has synced
+func InformersSynced(informers ...cache.InformerSynced) Check {
+	return func() error {
+		for _, synced := range informers {
+			if !synced() {
+				return fmt.Errorf("informer caches have not synced")
+			}
+		}
+		return nil
+	}
+}
+
+// ProgressStalled returns a Check that fails when work is in progress but
+// none has started on an idle queue or completed for longer than timeout
+func ProgressStalled(progress func() (int, time.Time), timeout time.Duration) Check {
+	return func() error {
+		inFlight, lastProgress := progress()
+		if inFlight > 0 && time.Since(lastProgress) > timeout {
+			return fmt.Errorf("%d operations in progress, none completed since %s", inFlight, lastProgress.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
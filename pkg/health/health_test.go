@@ -0,0 +1,65 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test the status and body served for passing and failing checks
+func TestCheckerHandler(t *testing.T) {
+	checker := NewChecker()
+	checker.AddHealthCheck("ok", func() error { return nil })
+	checker.AddReadyCheck("ok", func() error { return nil })
+	checker.AddReadyCheck("synced", func() error { return errors.New("not synced") })
+
+	for _, tc := range []struct {
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"/healthz", http.StatusOK, "[+]ok ok\n"},
+		{"/readyz", http.StatusInternalServerError, "[+]ok ok\n[-]synced failed: not synced\n"},
+	} {
+		recorder := httptest.NewRecorder()
+		checker.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if recorder.Code != tc.expectedCode {
+			t.Errorf("%s: expected status %d got %d", tc.path, tc.expectedCode, recorder.Code)
+		}
+		if body := recorder.Body.String(); body != tc.expectedBody {
+			t.Errorf("%s: expected body %q got %q", tc.path, tc.expectedBody, body)
+		}
+	}
+}
+
+// Test detection of stalled work
+func TestProgressStalled(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		inFlight     int
+		lastProgress time.Time
+		expectErr    bool
+	}{
+		{"Idle", 0, time.Now().Add(-time.Hour), false},
+		{"Progressing", 3, time.Now(), false},
+		{"Stalled", 3, time.Now().Add(-time.Hour), true},
+	} {
+		check := ProgressStalled(func() (int, time.Time) { return tc.inFlight, tc.lastProgress }, time.Minute)
+		if err := check(); (err != nil) != tc.expectErr {
+			t.Errorf("%s: unexpected result %v", tc.name, err)
+		}
+	}
+}
+
+// Test lease names match the ones the ObjectStorageController uses
+func TestLeaseName(t *testing.T) {
+	if name := LeaseName("leader-lock", "cosi-controller-manager"); name != "leader-lock-cosi-controller-manager" {
+		t.Errorf("unexpected lease name %q", name)
+	}
+	if name := LeaseName("Leader.Lock", "id/"); name != "leader-lock-id-X" {
+		t.Errorf("unexpected lease name %q", name)
+	}
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+var invalidLeaseChars = regexp.MustCompile("[^a-zA-Z0-9-]")
+
+// LeaderObserver follows the lease the ObjectStorageController uses for
+// leader election, without taking part in the election itself
+type LeaderObserver struct {
+	kubeClient kubeclientset.Interface
+	namespace  string
+	name       string
+	identity   string
+
+	lock    sync.RWMutex
+	holder  string
+	expires time.Time
+	err     error
+}
+
+// NewLeaderObserver returns a LeaderObserver for the lease namespace/name.
+// identity is the holder identity this process uses when it is the leader.
+func NewLeaderObserver(kubeClient kubeclientset.Interface, namespace, name, identity string) *LeaderObserver {
+	return &LeaderObserver{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		name:       name,
+		identity:   identity,
+	}
+}
+
+// Run polls the lease every period until ctx is cancelled
+func (l *LeaderObserver) Run(ctx context.Context, period time.Duration) {
+	wait.UntilWithContext(ctx, l.observe, period)
+}
+
+func (l *LeaderObserver) observe(ctx context.Context) {
+	lease, err := l.kubeClient.CoordinationV1().Leases(l.namespace).Get(ctx, l.name, metav1.GetOptions{})
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.err = err
+	l.holder = ""
+	l.expires = time.Time{}
+	if err != nil {
+		klog.V(5).ErrorS(err, "Get leader election lease error", "name", l.name, "ns", l.namespace)
+		return
+	}
+
+	if lease.Spec.HolderIdentity != nil {
+		l.holder = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+		l.expires = lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	}
+}
+
+// IsLeader reports whether this process currently holds the lease
+func (l *LeaderObserver) IsLeader() bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return l.holder != "" && l.holder == l.identity && time.Now().Before(l.expires)
+}
+
+// Decided is a Check that passes once the lease is held, by this process
+// or by another replica
+func (l *LeaderObserver) Decided() error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if l.err != nil {
+		return fmt.Errorf("leader election lease %s/%s: %w", l.namespace, l.name, l.err)
+	}
+	if l.holder == "" || time.Now().After(l.expires) {
+		return fmt.Errorf("leader election lease %s/%s is not held", l.namespace, l.name)
+	}
+	return nil
+}
+
+// LeaseName returns the name of the lease the ObjectStorageController elects
+// its leader with
+func LeaseName(leaderLockName, identity string) string {
+	return sanitizeLeaseName(fmt.Sprintf("%s/%s", leaderLockName, identity))
+}
+
+// LeaseHolderIdentity returns the identity the ObjectStorageController of
+// this process holds its lease with
+func LeaseHolderIdentity() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	return sanitizeLeaseName(hostname), nil
+}
+
+// LeaderElectionNamespace returns the namespace the ObjectStorageController
+// creates its lease in
+func LeaderElectionNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+
+	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		if ns := strings.TrimSpace(string(data)); len(ns) > 0 {
+			return ns
+		}
+	}
+	return "default"
+}
+
+// sanitizeLeaseName matches the sanitization the ObjectStorageController
+// applies to lease names and identities
+func sanitizeLeaseName(n string) string {
+	name := strings.ToLower(invalidLeaseChars.ReplaceAllString(n, "-"))
+	if strings.HasSuffix(name, "-") {
+		name = name + "X"
+	}
+	return name
+}
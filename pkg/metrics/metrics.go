@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+const namespace = "cosi_controller"
+
+// States a bucketClaim is counted in
+const (
+	BucketClaimPending     = "pending"
+	BucketClaimReady       = "ready"
+	BucketClaimTerminating = "terminating"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	provisionAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bucket_provision_attempts_total",
+		Help:      "Number of attempts to provision a bucket for a bucket claim.",
+	}, []string{"bucket_class", "driver"})
+
+	provisionSuccesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bucket_provision_successes_total",
+		Help:      "Number of bucket claims successfully provisioned.",
+	}, []string{"bucket_class", "driver"})
+
+	provisionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bucket_provision_failures_total",
+		Help:      "Number of failed attempts to provision a bucket for a bucket claim.",
+	}, []string{"bucket_class", "driver"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Time taken by listeners to process an Add, Update or Delete.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"resource", "operation"})
+
+	bucketClaims = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bucket_claims",
+		Help:      "Number of bucket claims known to the controller by state.",
+	}, []string{"state"})
+
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "errors_total",
+		Help:      "Number of errors returned by listeners by kind.",
+	}, []string{"kind"})
+
+	reconcilesInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reconciles_in_flight",
+		Help:      "Number of listener operations currently being processed.",
+	})
+)
+
+// errorKinds maps the error codes of the central controller to the value of
+// the kind label. Errors not listed here are counted as "other".
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{util.ErrBucketAlreadyExists, "bucket_already_exists"},
+	{util.ErrInvalidBucketClass, "invalid_bucket_class"},
+	{util.ErrNotImplemented, "not_implemented"},
+	{util.ErrMultipleDefaultBucketClasses, "multiple_default_bucket_classes"},
+	{util.ErrWaitingForBucketDeletion, "waiting_for_bucket_deletion"},
+	{util.ErrWaitingForBucketClaimStatus, "waiting_for_bucket_claim_status"},
+	{util.ErrInvalidBucketNamingStrategy, "invalid_bucket_naming_strategy"},
+	{util.ErrInvalidBucketName, "invalid_bucket_name"},
+	{util.ErrBucketNameConflict, "bucket_name_conflict"},
+	{util.ErrBucketNameNotCompliant, "bucket_name_not_compliant"},
+	{util.ErrBucketAlreadyBound, "bucket_already_bound"},
+	{util.ErrBucketClaimSpecImmutable, "bucket_claim_spec_immutable"},
+	{util.ErrInvalidDriverName, "invalid_driver_name"},
+	{util.ErrInvalidDeletionPolicy, "invalid_deletion_policy"},
+	{util.ErrNoProtocols, "no_protocols"},
+	{util.ErrUnknownProtocol, "unknown_protocol"},
+	{util.ErrProtocolMismatch, "protocol_mismatch"},
+	{util.ErrExistingBucketProtocols, "existing_bucket_protocols"},
+	{util.ErrProtocolInUse, "protocol_in_use"},
+	{util.ErrInvalidBucketAccessClass, "invalid_bucket_access_class"},
+	{util.ErrInvalidBucketClaim, "invalid_bucket_claim"},
+	{util.ErrBucketClaimNotBound, "bucket_claim_not_bound"},
+	{util.ErrUnsupportedProtocol, "unsupported_protocol"},
+}
+
+var (
+	claimStatesLock sync.Mutex
+	claimStates     = map[string]string{}
+
+	progressLock sync.Mutex
+	inFlight     int
+	lastProgress time.Time
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		provisionAttempts,
+		provisionSuccesses,
+		provisionFailures,
+		reconcileDuration,
+		bucketClaims,
+		errorsTotal,
+		reconcilesInFlight,
+	)
+
+	for _, state := range []string{BucketClaimPending, BucketClaimReady, BucketClaimTerminating} {
+		bucketClaims.WithLabelValues(state).Set(0)
+	}
+}
+
+// Handler returns the HTTP handler serving the controller metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RecordProvision counts an attempt to provision a bucket and its outcome
+func RecordProvision(bucketClassName, driverName string, err error) {
+	provisionAttempts.WithLabelValues(bucketClassName, driverName).Inc()
+	if err != nil {
+		provisionFailures.WithLabelValues(bucketClassName, driverName).Inc()
+		return
+	}
+	provisionSuccesses.WithLabelValues(bucketClassName, driverName).Inc()
+}
+
+// StartReconcile marks the start of a listener operation. The returned
+// function must be called with the result of the operation once it is done.
+func StartReconcile(resource, operation string) func(err error) {
+	start := time.Now()
+	updateProgress(1)
+
+	return func(err error) {
+		updateProgress(-1)
+		reconcileDuration.WithLabelValues(resource, operation).Observe(time.Since(start).Seconds())
+		if err != nil {
+			errorsTotal.WithLabelValues(ErrorKind(err)).Inc()
+		}
+	}
+}
+
+// ReconcileProgress returns the number of listener operations in progress and
+// the last time an operation started while none were running or completed
+func ReconcileProgress() (int, time.Time) {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	return inFlight, lastProgress
+}
+
+func updateProgress(delta int) {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	if delta < 0 || inFlight == 0 {
+		lastProgress = time.Now()
+	}
+	inFlight += delta
+	reconcilesInFlight.Set(float64(inFlight))
+}
+
+// ErrorKind returns the kind label an error is counted under
+func ErrorKind(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return "other"
+}
+
+// SetBucketClaimState records the current state of the bucketClaim with the given key
+func SetBucketClaimState(key, state string) {
+	claimStatesLock.Lock()
+	defer claimStatesLock.Unlock()
+
+	if old, ok := claimStates[key]; ok {
+		if old == state {
+			return
+		}
+		bucketClaims.WithLabelValues(old).Dec()
+	}
+	claimStates[key] = state
+	bucketClaims.WithLabelValues(state).Inc()
+}
+
+// ForgetBucketClaim stops counting the bucketClaim with the given key
+func ForgetBucketClaim(key string) {
+	claimStatesLock.Lock()
+	defer claimStatesLock.Unlock()
+
+	if old, ok := claimStates[key]; ok {
+		bucketClaims.WithLabelValues(old).Dec()
+		delete(claimStates, key)
+	}
+}
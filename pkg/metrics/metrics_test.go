@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+// Test classification of errors by kind
+func TestErrorKind(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		kind string
+	}{
+		{util.ErrInvalidBucketClass, "invalid_bucket_class"},
+		{fmt.Errorf("%w: bucket %q", util.ErrBucketAlreadyBound, "bucket1"), "bucket_already_bound"},
+		{fmt.Errorf("%w: %q", util.ErrBucketNameNotCompliant, "bucket1"), "bucket_name_not_compliant"},
+		{errors.New("connection refused"), "other"},
+	} {
+		if kind := ErrorKind(tc.err); kind != tc.kind {
+			t.Errorf("expected kind %q for %v got %q", tc.kind, tc.err, kind)
+		}
+	}
+}
+
+// Test that every error of the util package is counted as a kind of its own.
+// The errors are read from the sources, so that new errors are not missed.
+func TestErrorKindsComplete(t *testing.T) {
+	files, err := filepath.Glob("../util/*.go")
+	if err != nil {
+		t.Fatalf("Error occurred when listing util sources: %v", err)
+	}
+
+	messages := map[string]string{}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatalf("Error occurred when parsing %s: %v", file, err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+			for i, name := range spec.Names {
+				if !name.IsExported() || !strings.HasPrefix(name.Name, "Err") || i >= len(spec.Values) {
+					continue
+				}
+				call, ok := spec.Values[i].(*ast.CallExpr)
+				if !ok || len(call.Args) != 1 {
+					t.Errorf("%s is not declared with errors.New", name.Name)
+					continue
+				}
+				lit, ok := call.Args[0].(*ast.BasicLit)
+				if !ok {
+					t.Errorf("%s is not declared with a literal message", name.Name)
+					continue
+				}
+				messages[name.Name], _ = strconv.Unquote(lit.Value)
+			}
+			return true
+		})
+	}
+	if len(messages) == 0 {
+		t.Fatalf("no errors found in util sources")
+	}
+
+	kinds := map[string]bool{}
+	for _, k := range errorKinds {
+		if kinds[k.kind] {
+			t.Errorf("kind %q is used for more than one error", k.kind)
+		}
+		kinds[k.kind] = true
+	}
+	for name, message := range messages {
+		found := 0
+		for _, k := range errorKinds {
+			if k.err.Error() == message {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("expected util.%s to have one kind got %d", name, found)
+		}
+	}
+}
+
+// Test counting bucketClaims by state
+func TestBucketClaimStates(t *testing.T) {
+	SetBucketClaimState("default/claim1", BucketClaimPending)
+	SetBucketClaimState("default/claim2", BucketClaimPending)
+	SetBucketClaimState("default/claim1", BucketClaimReady)
+	SetBucketClaimState("default/claim1", BucketClaimReady)
+	SetBucketClaimState("default/claim2", BucketClaimTerminating)
+	ForgetBucketClaim("default/claim2")
+
+	for state, expected := range map[string]float64{
+		BucketClaimPending:     0,
+		BucketClaimReady:       1,
+		BucketClaimTerminating: 0,
+	} {
+		if value := testutil.ToFloat64(bucketClaims.WithLabelValues(state)); value != expected {
+			t.Errorf("expected %v bucketClaims %s got %v", expected, state, value)
+		}
+	}
+}
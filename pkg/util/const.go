@@ -6,6 +6,92 @@ import (
 
 const (
 	BucketClaimFinalizer = "cosi.objectstorage.k8s.io/bucketclaim-protection"
+
+	// BucketClassFinalizer protects a bucketClass from deletion while
+	// bucketClaims or buckets reference it.
+	BucketClassFinalizer = "cosi.objectstorage.k8s.io/bucketclass-protection"
+	// BucketAccessClassFinalizer protects a bucketAccessClass from deletion
+	// while bucketAccesses reference it.
+	BucketAccessClassFinalizer = "cosi.objectstorage.k8s.io/bucketaccessclass-protection"
+
+	// FieldManager identifies the writes of the central controller
+	FieldManager = "cosi-controller-manager"
+
+	// BucketReleasedAnnotation is set on a Retain bucket once the
+	// bucketClaim it was bound to has been deleted.
+	BucketReleasedAnnotation = "cosi.objectstorage.k8s.io/bucket-released"
+
+	// BucketIDAnnotation mirrors the backend ID of the bucket a bucketClaim
+	// is bound to.
+	BucketIDAnnotation = "cosi.objectstorage.k8s.io/bucket-id"
+
+	// BucketNameAnnotation reserves the name of the bucket being created for
+	// a bucketClaim, before the bucket is created.
+	BucketNameAnnotation = "cosi.objectstorage.k8s.io/bucket-name"
+
+	// RequeuedByBucketClassAnnotation is set on a waiting bucketClaim to the
+	// name and resourceVersion of the bucketClass whose creation or update
+	// requeued it.
+	RequeuedByBucketClassAnnotation = "cosi.objectstorage.k8s.io/requeued-by-bucket-class"
+
+	// IsDefaultBucketClassAnnotation marks a bucketClass as the cluster
+	// default when set to "true".
+	IsDefaultBucketClassAnnotation = "cosi.objectstorage.k8s.io/is-default-class"
+	// DefaultBucketClassAnnotation names the default bucketClass of a
+	// namespace, overriding the cluster default.
+	DefaultBucketClassAnnotation = "cosi.objectstorage.k8s.io/default-bucket-class"
+)
+
+// BucketClass parameters reserved for the central controller
+const (
+	// ReservedParameterPrefix prefixes the bucketClass parameters reserved
+	// for the central controller, which are not passed on to drivers.
+	ReservedParameterPrefix = "cosi.objectstorage.k8s.io/"
+	// BucketNamingStrategyParameter overrides the bucket naming strategy for
+	// bucketClaims of the bucketClass.
+	BucketNamingStrategyParameter = "cosi.objectstorage.k8s.io/bucket-naming-strategy"
+	// BucketNameTemplateParameter is the template used by the "template"
+	// naming strategy.
+	BucketNameTemplateParameter = "cosi.objectstorage.k8s.io/bucket-name-template"
+	// ProtocolsParameter is a comma separated list of the protocols that
+	// bucketClaims of the bucketClass may request.
+	ProtocolsParameter = "cosi.objectstorage.k8s.io/protocols"
+	// DefaultProtocolsParameter is a comma separated list of the protocols
+	// the defaulting webhook sets on bucketClaims of the bucketClass that
+	// request none.
+	DefaultProtocolsParameter = "cosi.objectstorage.k8s.io/default-protocols"
+)
+
+// Labels set on bucketClaims by the defaulting webhook
+const (
+	// BucketClassLabel names the bucketClass of a bucketClaim
+	BucketClassLabel = "cosi.objectstorage.k8s.io/bucket-class"
+	// DriverNameLabel names the driver of the bucketClass of a bucketClaim
+	DriverNameLabel = "cosi.objectstorage.k8s.io/driver-name"
+)
+
+// Event reasons emitted by the central controller in addition to the ones
+// defined by the API
+const (
+	BucketReleased    = "BucketReleased"
+	BucketProvisioned = "BucketProvisioned"
+	ProtocolMismatch  = "ProtocolMismatch"
+	// BucketClassInUse and BucketAccessClassInUse are recorded on classes
+	// whose deletion is blocked by the objects referencing them
+	BucketClassInUse       = "BucketClassInUse"
+	BucketAccessClassInUse = "BucketAccessClassInUse"
+	// BucketProtocolsUpdated and FailedUpdateBucketProtocols are recorded on
+	// bound bucketClaims whose protocols were edited
+	BucketProtocolsUpdated      = "BucketProtocolsUpdated"
+	FailedUpdateBucketProtocols = "FailedUpdateBucketProtocols"
+	// BucketClaimSpecImmutable is recorded on bound bucketClaims whose
+	// immutable fields were changed and reverted
+	BucketClaimSpecImmutable = "BucketClaimSpecImmutable"
+	// BucketNameFallback is recorded on bucketClaims whose generated bucket
+	// name breaks the naming rules of a requested protocol and was replaced,
+	// InvalidBucketName when it could not be replaced
+	BucketNameFallback = "BucketNameFallback"
+	InvalidBucketName  = "InvalidBucketName"
 )
 
 var (
@@ -13,4 +99,32 @@ var (
 	ErrBucketAlreadyExists = errors.New("a bucket already exists that matches the bucket claim")
 	ErrInvalidBucketClass  = errors.New("cannot find bucket class with the name specified in the bucket claim")
 	ErrNotImplemented      = errors.New("operation not implemented")
+
+	ErrMultipleDefaultBucketClasses = errors.New("more than one bucket class is marked as default")
+
+	ErrWaitingForBucketDeletion    = errors.New("waiting for the bucket of the bucket claim to be deleted")
+	ErrWaitingForBucketClaimStatus = errors.New("waiting for the bucket claim status to record its bucket")
+
+	ErrInvalidBucketNamingStrategy = errors.New("invalid bucket naming strategy")
+	ErrInvalidBucketName           = errors.New("invalid generated bucket name")
+	ErrBucketNameConflict          = errors.New("a bucket with the generated name is bound to another bucket claim")
+	ErrBucketNameNotCompliant      = errors.New("generated bucket name breaks the naming rules of a requested protocol")
+
+	ErrBucketAlreadyBound       = errors.New("existing bucket cannot be bound to the bucket claim")
+	ErrBucketClaimSpecImmutable = errors.New("bucket claim spec is immutable")
+
+	ErrInvalidDriverName     = errors.New("invalid driver name")
+	ErrInvalidDeletionPolicy = errors.New("invalid deletion policy")
+
+	ErrNoProtocols      = errors.New("bucket claim must request at least one protocol")
+	ErrUnknownProtocol  = errors.New("unknown protocol")
+	ErrProtocolMismatch = errors.New("protocols requested by the bucket claim cannot be served")
+
+	ErrExistingBucketProtocols = errors.New("protocols of an existing bucket cannot be changed through the bucket claim")
+	ErrProtocolInUse           = errors.New("protocol removed from the bucket claim is used by bucket accesses")
+
+	ErrInvalidBucketAccessClass = errors.New("cannot find bucket access class with the name specified in the bucket access")
+	ErrInvalidBucketClaim       = errors.New("cannot find bucket claim with the name specified in the bucket access")
+	ErrBucketClaimNotBound      = errors.New("bucket claim referenced by the bucket access is not bound to a bucket")
+	ErrUnsupportedProtocol      = errors.New("protocol requested by the bucket access is not supported by the bucket claim")
 )
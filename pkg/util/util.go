@@ -2,7 +2,9 @@ package util
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -25,6 +27,34 @@ func CopySS(m map[string]string) map[string]string {
 	return copy
 }
 
+// DriverParameters returns a copy of bucketClass parameters without the ones
+// reserved for the central controller
+func DriverParameters(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copy := make(map[string]string, len(m))
+	for k, v := range m {
+		if !strings.HasPrefix(k, ReservedParameterPrefix) {
+			copy[k] = v
+		}
+	}
+	return copy
+}
+
+// maxListedNames is the number of names JoinNames lists before summarizing
+// the rest
+const maxListedNames = 10
+
+// JoinNames joins names into a comma separated list for events, listing at
+// most maxListedNames of them
+func JoinNames(names []string) string {
+	if len(names) <= maxListedNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxListedNames], ", "), len(names)-maxListedNames)
+}
+
 // GetBuckets will wait and fetch expected number of buckets created by the test
 // This is used by bucket request unit tests
 func GetBuckets(ctx context.Context, client bucketclientset.Interface, numExpected int) *types.BucketList {
@@ -55,7 +85,7 @@ func ValidateBucket(bucket types.Bucket, bucketClaim types.BucketClaim, bucketCl
 		bucket.Spec.BucketClaim.Namespace == bucketClaim.ObjectMeta.Namespace &&
 		bucket.Spec.BucketClaim.UID == bucketClaim.ObjectMeta.UID &&
 		bucket.Spec.BucketClassName == bucketClass.ObjectMeta.Name &&
-		reflect.DeepEqual(bucket.Spec.Parameters, bucketClass.Parameters) &&
+		reflect.DeepEqual(bucket.Spec.Parameters, DriverParameters(bucketClass.Parameters)) &&
 		bucket.Spec.DriverName == bucketClass.DriverName &&
 		bucket.Spec.DeletionPolicy == bucketClass.DeletionPolicy)
 }
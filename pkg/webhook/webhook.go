@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketaccess"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketclaim"
+)
+
+// Paths the webhooks are served on
+const (
+	ValidateBucketClaimPath  = "/validate-bucketclaim"
+	ValidateBucketClassPath  = "/validate-bucketclass"
+	ValidateBucketAccessPath = "/validate-bucketaccess"
+	DefaultBucketClaimPath   = "/default-bucketclaim"
+)
+
+// maxRequestSize bounds the AdmissionReviews the server reads
+const maxRequestSize = 3 * 1024 * 1024
+
+// Server validates COSI objects at admission with the checks the controller
+// makes before acting on them, and fills in the fields new bucketClaims leave
+// to the controller
+type Server struct {
+	claims   *bucketclaim.BucketClaimListener
+	accesses *bucketaccess.BucketAccessListener
+}
+
+// NewServer returns a Server validating through the given listeners, which
+// must have their clients initialized
+func NewServer(claims *bucketclaim.BucketClaimListener, accesses *bucketaccess.BucketAccessListener) *Server {
+	return &Server{
+		claims:   claims,
+		accesses: accesses,
+	}
+}
+
+// patchOperation is an operation of the JSON patch a mutating webhook responds with
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// admitFunc admits an AdmissionRequest, returning the JSON patch to apply to
+// its object if any, or the reason to deny it
+type admitFunc func(context.Context, *admissionv1.AdmissionRequest) ([]byte, error)
+
+// Handler returns the HTTP handler serving the validating and mutating webhooks
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ValidateBucketClaimPath, func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, validating(s.validateBucketClaim))
+	})
+	mux.HandleFunc(ValidateBucketClassPath, func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, validating(s.validateBucketClass))
+	})
+	mux.HandleFunc(ValidateBucketAccessPath, func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, validating(s.validateBucketAccess))
+	})
+	mux.HandleFunc(DefaultBucketClaimPath, func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, s.defaultBucketClaim)
+	})
+	return mux
+}
+
+// validating returns an admitFunc that admits requests validate accepts
+// without patching them
+func validating(validate func(context.Context, *admissionv1.AdmissionRequest) error) admitFunc {
+	return func(ctx context.Context, request *admissionv1.AdmissionRequest) ([]byte, error) {
+		return nil, validate(ctx, request)
+	}
+}
+
+// serve decodes the AdmissionReview of r, admits its request with admit and
+// writes back the review with the response
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, admit admitFunc) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	request := review.Request
+	response := &admissionv1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: true,
+	}
+	patch, err := admit(r.Context(), request)
+	if err != nil {
+		klog.V(3).InfoS("Admission denied",
+			"kind", request.Kind.Kind,
+			"name", request.Name,
+			"ns", request.Namespace,
+			"operation", request.Operation,
+			"reason", err)
+		response.Allowed = false
+		response.Result = deniedStatus(err)
+	} else if patch != nil {
+		patchType := admissionv1.PatchTypeJSONPatch
+		response.PatchType = &patchType
+		response.Patch = patch
+	}
+
+	review.Request = nil
+	review.Response = response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.ErrorS(err, "Failed to write AdmissionReview")
+	}
+}
+
+// deniedStatus returns the status of a request denied by err. Failures to
+// read the objects a request refers to are reported as internal errors.
+func deniedStatus(err error) *metav1.Status {
+	status := &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: err.Error(),
+	}
+	if apiStatus, ok := err.(kubeerrors.APIStatus); ok && !kubeerrors.IsNotFound(err) {
+		status.Code = apiStatus.Status().Code
+		status.Reason = apiStatus.Status().Reason
+	}
+	return status
+}
+
+// validateBucketClaim validates new bucketClaims and changes to their spec.
+// Protocol edits of bound bucketClaims are checked against their bucket.
+func (s *Server) validateBucketClaim(ctx context.Context, request *admissionv1.AdmissionRequest) error {
+	bucketClaim := &v1alpha1.BucketClaim{}
+	if err := decode(request, bucketClaim); err != nil || request.Operation == admissionv1.Delete {
+		return err
+	}
+
+	if request.Operation == admissionv1.Update {
+		old := &v1alpha1.BucketClaim{}
+		if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(old.Spec, bucketClaim.Spec) || !bucketClaim.GetDeletionTimestamp().IsZero() {
+			return nil
+		}
+		if err := bucketclaim.ValidateBucketClaimUpdate(old, bucketClaim); err != nil {
+			return err
+		}
+		if old.Status.BucketName != "" {
+			return s.claims.ValidateBoundBucketClaimUpdate(ctx, bucketClaim)
+		}
+	}
+	return s.claims.ValidateBucketClaim(ctx, bucketClaim)
+}
+
+// validateBucketClass validates new and updated bucketClasses
+func (s *Server) validateBucketClass(ctx context.Context, request *admissionv1.AdmissionRequest) error {
+	bucketClass := &v1alpha1.BucketClass{}
+	if err := decode(request, bucketClass); err != nil || request.Operation == admissionv1.Delete {
+		return err
+	}
+	if !bucketClass.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+	return bucketclaim.ValidateBucketClass(bucketClass)
+}
+
+// validateBucketAccess validates new bucketAccesses and changes to their spec
+func (s *Server) validateBucketAccess(ctx context.Context, request *admissionv1.AdmissionRequest) error {
+	bucketAccess := &v1alpha1.BucketAccess{}
+	if err := decode(request, bucketAccess); err != nil || request.Operation == admissionv1.Delete {
+		return err
+	}
+
+	if request.Operation == admissionv1.Update {
+		old := &v1alpha1.BucketAccess{}
+		if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(old.Spec, bucketAccess.Spec) || !bucketAccess.GetDeletionTimestamp().IsZero() {
+			return nil
+		}
+	}
+	return s.accesses.ValidateBucketAccess(ctx, bucketAccess)
+}
+
+// defaultBucketClaim fills in the bucketClass, protocols and labels new
+// bucketClaims leave empty
+func (s *Server) defaultBucketClaim(ctx context.Context, request *admissionv1.AdmissionRequest) ([]byte, error) {
+	if request.Operation != admissionv1.Create {
+		return nil, nil
+	}
+	bucketClaim := &v1alpha1.BucketClaim{}
+	if err := decode(request, bucketClaim); err != nil {
+		return nil, err
+	}
+
+	defaulted := bucketClaim.DeepCopy()
+	if err := s.claims.DefaultBucketClaim(ctx, defaulted); err != nil {
+		return nil, err
+	}
+
+	patch := []patchOperation{}
+	if defaulted.Spec.BucketClassName != bucketClaim.Spec.BucketClassName {
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/bucketClassName", Value: defaulted.Spec.BucketClassName})
+	}
+	if !equality.Semantic.DeepEqual(defaulted.Spec.Protocols, bucketClaim.Spec.Protocols) {
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/protocols", Value: defaulted.Spec.Protocols})
+	}
+	if !equality.Semantic.DeepEqual(defaulted.ObjectMeta.Labels, bucketClaim.ObjectMeta.Labels) {
+		patch = append(patch, patchOperation{Op: "add", Path: "/metadata/labels", Value: defaulted.ObjectMeta.Labels})
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(patch)
+}
+
+// decode reads the object of request into obj. Deletions carry no object and
+// are left undecoded.
+func decode(request *admissionv1.AdmissionRequest, obj interface{}) error {
+	if request.Operation == admissionv1.Delete {
+		return nil
+	}
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return fmt.Errorf("cannot decode %s: %w", request.Kind.Kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetypes "k8s.io/apimachinery/pkg/types"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage/v1alpha1"
+	fakebucketclientset "sigs.k8s.io/container-object-storage-interface-api/client/clientset/versioned/fake"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketaccess"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/bucketclaim"
+	"sigs.k8s.io/container-object-storage-interface-controller/pkg/util"
+)
+
+var goldClass = v1alpha1.BucketClass{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "classgold",
+	},
+	DriverName:     "sample.cosi.driver",
+	DeletionPolicy: v1alpha1.DeletionPolicyDelete,
+}
+
+var goldAccessClass = v1alpha1.BucketAccessClass{
+	ObjectMeta: metav1.ObjectMeta{
+		Name: "accessclassgold",
+	},
+	DriverName:         "sample.cosi.driver",
+	AuthenticationType: v1alpha1.AuthenticationTypeKey,
+}
+
+var bucketClaim1 = v1alpha1.BucketClaim{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "bucketclaim1",
+		Namespace: "default",
+		UID:       "12345-67890",
+	},
+	Spec: v1alpha1.BucketClaimSpec{
+		BucketClassName: "classgold",
+		Protocols:       []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+	},
+}
+
+var bucketAccess1 = v1alpha1.BucketAccess{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "bucketaccess1",
+		Namespace: "default",
+	},
+	Spec: v1alpha1.BucketAccessSpec{
+		BucketClaimName:       "bucketclaim1",
+		BucketAccessClassName: "accessclassgold",
+		CredentialsSecretName: "bucketaccess1-creds",
+		Protocol:              v1alpha1.ProtocolS3,
+	},
+}
+
+// Test the admission of COSI objects
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	boundClaim := bucketClaim1.DeepCopy()
+	boundClaim.Status.BucketName = "bucket1"
+	reclassedClaim := boundClaim.DeepCopy()
+	reclassedClaim.Spec.BucketClassName = "classsilver"
+
+	noProtocolsClaim := bucketClaim1.DeepCopy()
+	noProtocolsClaim.Spec.Protocols = nil
+	otherClassClaim := bucketClaim1.DeepCopy()
+	otherClassClaim.Spec.BucketClassName = "classsilver"
+
+	invalidDriverClass := goldClass.DeepCopy()
+	invalidDriverClass.DriverName = "-sample"
+	invalidPolicyClass := goldClass.DeepCopy()
+	invalidPolicyClass.DeletionPolicy = "Keep"
+
+	otherClaimAccess := bucketAccess1.DeepCopy()
+	otherClaimAccess.Spec.BucketClaimName = "bucketclaim2"
+
+	// Buckets of bound bucketClaims whose protocols are edited
+	newBucket := func(name string, bucketClaim *v1alpha1.BucketClaim) *v1alpha1.Bucket {
+		return &v1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.BucketSpec{
+				DriverName:      "sample.cosi.driver",
+				BucketClassName: bucketClaim.Spec.BucketClassName,
+				DeletionPolicy:  v1alpha1.DeletionPolicyDelete,
+				Protocols:       []v1alpha1.Protocol{v1alpha1.ProtocolS3},
+				BucketClaim: &v1.ObjectReference{
+					Name:      bucketClaim.Name,
+					Namespace: bucketClaim.Namespace,
+					UID:       bucketClaim.UID,
+				},
+			},
+		}
+	}
+	withProtocols := func(bucketClaim *v1alpha1.BucketClaim, protocols ...v1alpha1.Protocol) *v1alpha1.BucketClaim {
+		bucketClaim = bucketClaim.DeepCopy()
+		bucketClaim.Spec.Protocols = protocols
+		return bucketClaim
+	}
+
+	s3Class := goldClass.DeepCopy()
+	s3Class.Name = "classs3"
+	s3Class.Parameters = map[string]string{util.ProtocolsParameter: "S3"}
+	s3ClassClaim := bucketClaim1.DeepCopy()
+	s3ClassClaim.Name = "bucketclaim3"
+	s3ClassClaim.UID = "bucketclaim3-uid"
+	s3ClassClaim.Spec.BucketClassName = s3Class.Name
+	s3ClassClaim.Status.BucketName = "bucket3"
+
+	existingClaim := bucketClaim1.DeepCopy()
+	existingClaim.Name = "bucketclaim4"
+	existingClaim.UID = "bucketclaim4-uid"
+	existingClaim.Spec.BucketClassName = ""
+	existingClaim.Spec.ExistingBucketName = "bucket2"
+	existingClaim.Status.BucketName = "bucket2"
+
+	objects := []runtime.Object{
+		goldClass.DeepCopy(), s3Class, goldAccessClass.DeepCopy(), bucketAccess1.DeepCopy(),
+		bucketClaim1.DeepCopy(), s3ClassClaim, existingClaim,
+		newBucket("bucket1", boundClaim), newBucket("bucket2", existingClaim), newBucket("bucket3", s3ClassClaim),
+	}
+
+	for _, tc := range []struct {
+		name            string
+		path            string
+		operation       admissionv1.Operation
+		object          runtime.Object
+		oldObject       runtime.Object
+		expectedMessage string
+	}{
+		{
+			name:      "ValidBucketClaim",
+			path:      ValidateBucketClaimPath,
+			operation: admissionv1.Create,
+			object:    &bucketClaim1,
+		},
+		{
+			name:            "BucketClaimWithoutProtocols",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Create,
+			object:          noProtocolsClaim,
+			expectedMessage: util.ErrNoProtocols.Error(),
+		},
+		{
+			name:            "BucketClaimClassNotFound",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Create,
+			object:          otherClassClaim,
+			expectedMessage: "\"classsilver\" not found",
+		},
+		{
+			name:            "BucketClaimExistingBucketNotFound",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Create,
+			object:          &v1alpha1.BucketClaim{Spec: v1alpha1.BucketClaimSpec{ExistingBucketName: "missing", Protocols: []v1alpha1.Protocol{v1alpha1.ProtocolS3}}},
+			expectedMessage: "\"missing\" not found",
+		},
+		{
+			name:            "BoundBucketClaimClassChange",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Update,
+			object:          reclassedClaim,
+			oldObject:       boundClaim,
+			expectedMessage: util.ErrBucketClaimSpecImmutable.Error(),
+		},
+		{
+			name:      "BoundBucketClaimProtocolAdded",
+			path:      ValidateBucketClaimPath,
+			operation: admissionv1.Update,
+			object:    withProtocols(boundClaim, v1alpha1.ProtocolS3, v1alpha1.ProtocolAzure),
+			oldObject: boundClaim,
+		},
+		{
+			name:            "BoundBucketClaimUnknownProtocol",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Update,
+			object:          withProtocols(boundClaim, v1alpha1.ProtocolS3, "FTP"),
+			oldObject:       boundClaim,
+			expectedMessage: util.ErrUnknownProtocol.Error(),
+		},
+		{
+			name:            "BoundBucketClaimProtocolInUse",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Update,
+			object:          withProtocols(boundClaim, v1alpha1.ProtocolAzure),
+			oldObject:       boundClaim,
+			expectedMessage: util.ErrProtocolInUse.Error(),
+		},
+		{
+			name:            "BoundBucketClaimProtocolNotAllowed",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Update,
+			object:          withProtocols(s3ClassClaim, v1alpha1.ProtocolS3, v1alpha1.ProtocolGCP),
+			oldObject:       s3ClassClaim,
+			expectedMessage: util.ErrProtocolMismatch.Error(),
+		},
+		{
+			name:            "ExistingBucketClaimProtocolAdded",
+			path:            ValidateBucketClaimPath,
+			operation:       admissionv1.Update,
+			object:          withProtocols(existingClaim, v1alpha1.ProtocolS3, v1alpha1.ProtocolAzure),
+			oldObject:       existingClaim,
+			expectedMessage: util.ErrExistingBucketProtocols.Error(),
+		},
+		{
+			name:      "BucketClaimDeletion",
+			path:      ValidateBucketClaimPath,
+			operation: admissionv1.Delete,
+			oldObject: boundClaim,
+		},
+		{
+			name:      "ValidBucketClass",
+			path:      ValidateBucketClassPath,
+			operation: admissionv1.Create,
+			object:    &goldClass,
+		},
+		{
+			name:            "BucketClassInvalidDriverName",
+			path:            ValidateBucketClassPath,
+			operation:       admissionv1.Create,
+			object:          invalidDriverClass,
+			expectedMessage: util.ErrInvalidDriverName.Error(),
+		},
+		{
+			name:            "BucketClassInvalidDeletionPolicy",
+			path:            ValidateBucketClassPath,
+			operation:       admissionv1.Update,
+			object:          invalidPolicyClass,
+			oldObject:       &goldClass,
+			expectedMessage: util.ErrInvalidDeletionPolicy.Error(),
+		},
+		{
+			name:      "ValidBucketAccess",
+			path:      ValidateBucketAccessPath,
+			operation: admissionv1.Create,
+			object:    &bucketAccess1,
+		},
+		{
+			name:            "BucketAccessClaimNotFound",
+			path:            ValidateBucketAccessPath,
+			operation:       admissionv1.Create,
+			object:          otherClaimAccess,
+			expectedMessage: "\"bucketclaim2\" not found",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fakebucketclientset.NewSimpleClientset(objects...)
+			kubeClient := fakekubeclientset.NewSimpleClientset()
+			claims := bucketclaim.NewBucketClaimListener()
+			claims.InitializeKubeClient(kubeClient)
+			claims.InitializeBucketClient(client)
+			accesses := bucketaccess.NewBucketAccessListener()
+			accesses.InitializeKubeClient(kubeClient)
+			accesses.InitializeBucketClient(client)
+
+			request := &admissionv1.AdmissionRequest{
+				UID:       kubetypes.UID(tc.name),
+				Operation: tc.operation,
+				Object:    runtime.RawExtension{Object: tc.object},
+				OldObject: runtime.RawExtension{Object: tc.oldObject},
+			}
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request:  request,
+			})
+			if err != nil {
+				t.Fatalf("Error occurred when encoding AdmissionReview: %v", err)
+			}
+
+			recorder := httptest.NewRecorder()
+			NewServer(claims, accesses).Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tc.path, bytes.NewReader(body)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status %d got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+			}
+
+			review := &admissionv1.AdmissionReview{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), review); err != nil {
+				t.Fatalf("Error occurred when decoding AdmissionReview: %v", err)
+			}
+			response := review.Response
+			if response == nil || response.UID != request.UID {
+				t.Fatalf("expected a response to request %s got %+v", request.UID, response)
+			}
+			if tc.expectedMessage == "" {
+				if !response.Allowed {
+					t.Errorf("expected request to be allowed got %+v", response.Result)
+				}
+				return
+			}
+			if response.Allowed || response.Result == nil || !strings.Contains(response.Result.Message, tc.expectedMessage) {
+				t.Errorf("expected request to be denied with %q got %+v", tc.expectedMessage, response)
+			}
+		})
+	}
+}
+
+// Test the patch defaulting new bucketClaims
+func TestServerDefaultBucketClaim(t *testing.T) {
+	defaultClass := goldClass.DeepCopy()
+	defaultClass.Annotations = map[string]string{util.IsDefaultBucketClassAnnotation: "true"}
+	defaultClass.Parameters = map[string]string{util.DefaultProtocolsParameter: "S3"}
+
+	client := fakebucketclientset.NewSimpleClientset(defaultClass)
+	claims := bucketclaim.NewBucketClaimListener()
+	claims.InitializeKubeClient(fakekubeclientset.NewSimpleClientset())
+	claims.InitializeBucketClient(client)
+	server := NewServer(claims, bucketaccess.NewBucketAccessListener())
+
+	admit := func(operation admissionv1.Operation, bucketClaim *v1alpha1.BucketClaim) *admissionv1.AdmissionResponse {
+		body, err := json.Marshal(&admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       "default",
+				Operation: operation,
+				Object:    runtime.RawExtension{Object: bucketClaim},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error occurred when encoding AdmissionReview: %v", err)
+		}
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, DefaultBucketClaimPath, bytes.NewReader(body)))
+		review := &admissionv1.AdmissionReview{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), review); err != nil || review.Response == nil {
+			t.Fatalf("Error occurred when decoding AdmissionReview: %v", err)
+		}
+		if !review.Response.Allowed {
+			t.Fatalf("expected request to be allowed got %+v", review.Response.Result)
+		}
+		return review.Response
+	}
+
+	emptyClaim := bucketClaim1.DeepCopy()
+	emptyClaim.Spec.BucketClassName = ""
+	emptyClaim.Spec.Protocols = nil
+	response := admit(admissionv1.Create, emptyClaim)
+	if response.PatchType == nil || *response.PatchType != admissionv1.PatchTypeJSONPatch {
+		t.Fatalf("expected a JSON patch got %+v", response)
+	}
+	patch := []patchOperation{}
+	if err := json.Unmarshal(response.Patch, &patch); err != nil {
+		t.Fatalf("Error occurred when decoding patch: %v", err)
+	}
+	expected := map[string]string{
+		"/spec/bucketClassName": `"classgold"`,
+		"/spec/protocols":       `["S3"]`,
+		"/metadata/labels":      `{"cosi.objectstorage.k8s.io/bucket-class":"classgold","cosi.objectstorage.k8s.io/driver-name":"sample.cosi.driver"}`,
+	}
+	if len(patch) != len(expected) {
+		t.Errorf("expected %d patch operations got %+v", len(expected), patch)
+	}
+	for _, operation := range patch {
+		value, err := json.Marshal(operation.Value)
+		if err != nil {
+			t.Fatalf("Error occurred when encoding patch value: %v", err)
+		}
+		if operation.Op != "add" || expected[operation.Path] != string(value) {
+			t.Errorf("unexpected patch operation %s %s %s", operation.Op, operation.Path, value)
+		}
+	}
+
+	// Updates and complete bucketClaims are not patched
+	if response := admit(admissionv1.Update, emptyClaim); response.Patch != nil {
+		t.Errorf("expected updates not to be patched got %s", response.Patch)
+	}
+	complete := bucketClaim1.DeepCopy()
+	complete.Labels = map[string]string{
+		util.BucketClassLabel: "classgold",
+		util.DriverNameLabel:  "sample.cosi.driver",
+	}
+	if response := admit(admissionv1.Create, complete); response.Patch != nil {
+		t.Errorf("expected complete bucketClaim not to be patched got %s", response.Patch)
+	}
+}
+
+// Test rejecting requests that are not AdmissionReviews
+func TestServerBadRequest(t *testing.T) {
+	server := NewServer(bucketclaim.NewBucketClaimListener(), bucketaccess.NewBucketAccessListener())
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, ValidateBucketClaimPath, strings.NewReader("{")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, ValidateBucketClaimPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}